@@ -33,3 +33,12 @@ func BenchmarkAlreadyWrappedWithVars(b *testing.B) {
 		Wrap(err, With("foo", "bar"), Critical(), Code(codes.FailedPrecondition))
 	}
 }
+
+//nolint:errcheck
+func BenchmarkInitialWrapAndError(b *testing.B) {
+	b.ReportAllocs()
+	err := errors.New("my special error")
+	for n := 0; n < b.N; n++ {
+		_ = Wrap(err).Error()
+	}
+}
@@ -0,0 +1,123 @@
+package e
+
+import (
+	"strings"
+)
+
+// Layer is one error in a chain walked with Chain
+type Layer struct {
+	// Err is the error at this layer of the chain
+	Err error
+
+	// Msg is the context this layer adds to the layers below it, e.g. "loading user"
+	// for fmt.Errorf("loading user: %w", err). For an Err it's the messages of its
+	// frames, outermost first, and for the last layer it's the whole error message.
+	// If a wrapped error isn't at the end of the message, its place is marked with %w.
+	Msg string
+}
+
+// ChainIterator walks the layers of an error, see Chain
+type ChainIterator struct {
+	next  error
+	layer Layer
+}
+
+// Chain returns an iterator over every layer of err, starting with err itself and
+// following Err, stdlib Unwrap() error and pkg/errors Cause() error. Errors with several
+// causes, such as those from Join, end the chain, use Children to walk them.
+//
+//	for it := e.Chain(err); it.Next(); {
+//		layer := it.Layer()
+//		...
+//	}
+func Chain(err error) *ChainIterator {
+	return &ChainIterator{next: err}
+}
+
+// Next advances to the next layer, returning false once there are none left
+func (it *ChainIterator) Next() bool {
+	if it.next == nil {
+		return false
+	}
+
+	cur := it.next
+	it.next = unwrapOnce(cur)
+	it.layer = Layer{Err: cur, Msg: layerMsg(cur, it.next)}
+
+	return true
+}
+
+// Layer returns the current layer
+func (it *ChainIterator) Layer() Layer {
+	return it.layer
+}
+
+// unwrapOnce returns the error wrapped by rawErr, or nil if there isn't one
+func unwrapOnce(rawErr error) error {
+	switch err := rawErr.(type) {
+	case *Err:
+		return err.rootErr
+	case unwrapper:
+		return err.Unwrap()
+	case causer:
+		return err.Cause()
+	default:
+		return nil
+	}
+}
+
+// layerMsg returns the part of the message of cur that isn't from next
+func layerMsg(cur, next error) string {
+	if err, ok := cur.(*Err); ok {
+		return err.frameMsgs()
+	}
+
+	outer := cur.Error()
+	if next == nil {
+		return outer
+	}
+
+	// fmt.Errorf embeds an Err with its short message, and Error() would render the full
+	// report. Messages may have been added to its outer frames since, so the short message
+	// is tried without each of them in turn, down to the root message.
+	var inners []string
+	if err, ok := next.(*Err); ok {
+		msgs := err.frameMsgList()
+		for i := range msgs {
+			inners = append(inners, err.cl().scrub.str(strings.Join(msgs[i:], ": "))+": "+err.rootErr.Error())
+		}
+		inners = append(inners, err.rootErr.Error())
+	} else {
+		inners = []string{next.Error()}
+	}
+
+	for _, inner := range inners {
+		switch {
+		case strings.HasSuffix(outer, inner):
+			return strings.TrimRight(strings.TrimSuffix(outer, inner), ": ")
+
+		case strings.Contains(outer, inner):
+			return strings.Replace(outer, inner, "%w", 1)
+		}
+	}
+
+	return outer
+}
+
+// intermediateMsgs joins the messages of the layers between rawErr and the Err
+// that was found in its chain, or returns "" if the chain doesn't lead to it
+func intermediateMsgs(rawErr error, err *Err) string {
+	var msgs []string
+	for it := Chain(rawErr); it.Next(); {
+		layer := it.Layer()
+		if layer.Err == error(err) {
+			return strings.Join(msgs, ": ")
+		}
+
+		if layer.Msg != "" {
+			msgs = append(msgs, layer.Msg)
+		}
+	}
+
+	return ""
+}
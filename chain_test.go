@@ -0,0 +1,69 @@
+package e
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// causeErr mimics older versions of pkg/errors, which only expose Cause
+type causeErr struct {
+	msg   string
+	cause error
+}
+
+func (ce *causeErr) Error() string { return ce.msg + ": " + ce.cause.Error() }
+func (ce *causeErr) Cause() error  { return ce.cause }
+
+func TestChain(t *testing.T) {
+	err := fmt.Errorf("loading user: %w", &causeErr{"from cache", fmt.Errorf("retry (%w) failed", Wrap(io.EOF, Msg("reading")))})
+
+	var got []string
+	for it := Chain(err); it.Next(); {
+		got = append(got, it.Layer().Msg)
+	}
+
+	assert.Equal(t, []string{
+		"loading user",
+		"from cache",
+		"retry (%w) failed",
+		"reading",
+		"EOF",
+	}, got)
+
+	assert.False(t, Chain(nil).Next())
+}
+
+func TestWrapIntermediateMsgs(t *testing.T) {
+	err := intermediateHelper()
+	got := Wrap(fmt.Errorf("loading user: %w", fmt.Errorf("from cache: %w", err)), Msg("handling request"))
+
+	assert.Same(t, err, got)
+	assert.Equal(t, "handling request: loading user: from cache: querying users: no rows", got.shortString())
+
+	// without options, the intermediate messages still have to be recorded
+	err = intermediateHelper()
+	got = Wrap(fmt.Errorf("loading user: %w", err))
+	assert.Equal(t, "loading user: querying users: no rows", got.shortString())
+
+	// wrapping the same chain again doesn't add its messages again, even once the
+	// Err's own messages no longer match those fmt.Errorf embedded
+	err = intermediateHelper()
+	rawErr := fmt.Errorf("loading user: %w", err)
+	Wrap(rawErr, Msg("retrying"))
+	got = Wrap(rawErr)
+	assert.Equal(t, "retrying: loading user: querying users: no rows", got.shortString())
+
+	// the layer of an Err is compared with its short message, not the full report
+	assert.Equal(t, "loading user", layerMsg(rawErr, err))
+
+	// there are no intermediate layers if the chain doesn't lead to the Err
+	assert.Equal(t, "", intermediateMsgs(errors.New("other"), err))
+}
+
+func intermediateHelper() *Err {
+	return New("no rows", Msg("querying users"))
+}
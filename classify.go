@@ -0,0 +1,106 @@
+package e
+
+import (
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FrameClasses declares which import paths count as application code, internal
+// shared packages and vendored code. Each entry is a module or package path
+// prefix, e.g. "github.com/acme/app". When multiple prefixes match a frame, the
+// longest one wins, so "github.com/acme/app/pkg" can be classified as Pkg while
+// the rest of "github.com/acme/app" is App. Frames that don't match any prefix
+// are classified as stdlib or vendor code.
+type FrameClasses struct {
+	App    []string
+	Pkg    []string
+	Vendor []string
+}
+
+// DefaultFrameClasses returns the classification used when SetFrameClasses hasn't
+// been called, where the main module from debug.ReadBuildInfo is application code.
+func DefaultFrameClasses() FrameClasses {
+	var fc FrameClasses
+
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Path != "" {
+		fc.App = []string{bi.Main.Path}
+	}
+
+	return fc
+}
+
+// SetFrameClasses replaces the rules the default client uses to classify stack frames.
+// Classes are assigned when frames are parsed, so this should be called at app startup.
+func SetFrameClasses(fc FrameClasses) {
+	DefaultClient().SetFrameClasses(fc)
+}
+
+type classRule struct {
+	prefix string
+	class  class
+}
+
+// classRuleSet is sorted longest prefix first, so the first match wins
+type classRuleSet struct {
+	mu    sync.RWMutex
+	rules []classRule
+}
+
+func newClassRuleSet(fc FrameClasses) *classRuleSet {
+	crs := &classRuleSet{}
+	crs.set(fc)
+	return crs
+}
+
+func (crs *classRuleSet) set(fc FrameClasses) {
+	rules := make([]classRule, 0, len(fc.App)+len(fc.Pkg)+len(fc.Vendor))
+	for _, group := range []struct {
+		prefixes []string
+		class    class
+	}{
+		{fc.App, classApp},
+		{fc.Pkg, classPkg},
+		{fc.Vendor, classVendor},
+	} {
+		for _, prefix := range group.prefixes {
+			// an empty prefix would match every frame
+			if prefix != "" {
+				rules = append(rules, classRule{prefix: prefix, class: group.class})
+			}
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].prefix) > len(rules[j].prefix)
+	})
+
+	crs.mu.Lock()
+	crs.rules = rules
+	crs.mu.Unlock()
+}
+
+// classify returns the class of the longest matching prefix, if any
+func (crs *classRuleSet) classify(importPath string) (class, bool) {
+	crs.mu.RLock()
+	defer crs.mu.RUnlock()
+
+	for _, rule := range crs.rules {
+		if hasPathPrefix(importPath, rule.prefix) {
+			return rule.class, true
+		}
+	}
+
+	return 0, false
+}
+
+// hasPathPrefix reports whether prefix matches whole path elements of importPath,
+// so that "github.com/acme/app" doesn't match "github.com/acme/application"
+func hasPathPrefix(importPath, prefix string) bool {
+	if !strings.HasPrefix(importPath, prefix) {
+		return false
+	}
+
+	return len(importPath) == len(prefix) || strings.HasSuffix(prefix, "/") || importPath[len(prefix)] == '/'
+}
@@ -0,0 +1,36 @@
+package e
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	crs := &classRuleSet{}
+	crs.set(FrameClasses{
+		App:    []string{"github.com/acme/app"},
+		Pkg:    []string{"github.com/acme/app/pkg"},
+		Vendor: []string{"github.com/acme/app/vendor", ""},
+	})
+
+	tests := []struct {
+		importPath string
+		want       class
+		wantOK     bool
+	}{
+		{"github.com/acme/app", classApp, true},
+		{"github.com/acme/app/cmd/server", classApp, true},
+		{"github.com/acme/app/pkg/router", classPkg, true},
+		{"github.com/acme/app/vendor/github.com/spf13/cobra", classVendor, true},
+		{"github.com/acme/application", 0, false},
+		{"github.com/spf13/cobra", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.importPath, func(t *testing.T) {
+			got, ok := crs.classify(tt.importPath)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package e
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Client holds the configuration used to parse, render and report errors. All of its
+// methods are safe for concurrent use, so separate clients can be used by parallel
+// tests or by the tenants of a multi-tenant binary.
+//
+// The package level functions, such as AddReporter and SetFrameClasses, configure
+// the default client, which is used by every error that isn't reported with
+// ReportWithClient.
+type Client struct {
+	reporters  *reporterRegistry
+	classRules *classRuleSet
+	stackRules *stackRuleSet
+	labelers   *labelerSet
+
+	linkBuilderMu sync.RWMutex
+	linkBuilder   LinkBuilder
+
+	runtimeStatsMode int32
+
+	scrub           *scrubber
+	sampler         *sampler
+	detailRenderers *detailRendererSet
+	queue           *reportQueue
+
+	propagationMu     sync.RWMutex
+	acceptPropagation Propagation
+
+	// environment variables of the process, only the allowlisted ones are reported
+	envVars []envVar
+}
+
+// ClientOption configures a Client created with NewClient
+type ClientOption func(cl *Client)
+
+// NewClient returns a client configured with the defaults, which are the same as
+// those of the default client at startup, and then the given options
+func NewClient(opts ...ClientOption) *Client {
+	cl := &Client{
+		reporters:        &reporterRegistry{},
+		classRules:       newClassRuleSet(DefaultFrameClasses()),
+		stackRules:       newStackRuleSet(DefaultStackRules()),
+		labelers:         &labelerSet{},
+		linkBuilder:      DefaultLinkBuilder(),
+		runtimeStatsMode: int32(RuntimeStatsAtReport),
+		scrub:            newScrubber(DefaultScrubRules()),
+		sampler:          newSampler(nil),
+		detailRenderers:  newDetailRendererSet(),
+		envVars:          environ(),
+	}
+
+	for _, opt := range opts {
+		opt(cl)
+	}
+
+	if cl.queue == nil {
+		cl.queue = newReportQueue(cl, DefaultQueueConfig())
+	}
+
+	return cl
+}
+
+// WithReporter registers a reporter on the client, see AddReporter
+func WithReporter(name string, r Reporter) ClientOption {
+	return func(cl *Client) {
+		cl.AddReporter(name, r)
+	}
+}
+
+// WithFrameClasses sets the frame classification of the client, see SetFrameClasses
+func WithFrameClasses(fc FrameClasses) ClientOption {
+	return func(cl *Client) {
+		cl.SetFrameClasses(fc)
+	}
+}
+
+// WithStackRules replaces the stack rules of the client, see SetStackRules
+func WithStackRules(rules ...StackRule) ClientOption {
+	return func(cl *Client) {
+		cl.SetStackRules(rules...)
+	}
+}
+
+// WithLinkBuilder sets the link builder of the client, see SetLinkBuilder
+func WithLinkBuilder(lb LinkBuilder) ClientOption {
+	return func(cl *Client) {
+		cl.SetLinkBuilder(lb)
+	}
+}
+
+// WithContextLabeler adds a context labeler to the client, see AddContextLabeler
+func WithContextLabeler(l ContextLabeler) ClientOption {
+	return func(cl *Client) {
+		cl.AddContextLabeler(l)
+	}
+}
+
+// WithRuntimeStats sets when the client captures runtime statistics, see SetRuntimeStats
+func WithRuntimeStats(mode RuntimeStatsMode) ClientOption {
+	return func(cl *Client) {
+		cl.SetRuntimeStats(mode)
+	}
+}
+
+// WithScrubRules sets the rules the client scrubs errors with, see SetScrubRules
+func WithScrubRules(sr ScrubRules) ClientOption {
+	return func(cl *Client) {
+		cl.SetScrubRules(sr)
+	}
+}
+
+// WithSampleRules sets the rules the client throttles reports with, see SetSampleRules
+func WithSampleRules(rules ...SampleRule) ClientOption {
+	return func(cl *Client) {
+		cl.SetSampleRules(rules...)
+	}
+}
+
+var (
+	defaultClientMu sync.RWMutex
+	defaultClient   = NewClient()
+)
+
+// DefaultClient returns the client configured by the package level functions
+func DefaultClient() *Client {
+	defaultClientMu.RLock()
+	defer defaultClientMu.RUnlock()
+
+	return defaultClient
+}
+
+// SetDefaultClient replaces the client configured by the package level functions.
+// Passing nil resets it to a new client with the defaults.
+func SetDefaultClient(cl *Client) {
+	if cl == nil {
+		cl = NewClient()
+	}
+
+	defaultClientMu.Lock()
+	defaultClient = cl
+	defaultClientMu.Unlock()
+}
+
+// ReportWithClient reports the error with the given client instead of the default
+// client. If the stack hasn't been resolved yet, the client's frame classes and
+// stack rules are used for it.
+func ReportWithClient(cl *Client) ReportOption {
+	return func(err *Err) {
+		err.setClient(cl)
+	}
+}
+
+// setClient sets the client of the error and of any joined errors
+func (err *Err) setClient(cl *Client) {
+	err.client.Store(cl)
+	for _, child := range err.children {
+		child.setClient(cl)
+	}
+}
+
+// cl returns the client the error was reported with, or the default client
+func (err *Err) cl() *Client {
+	if cl := err.client.Load(); cl != nil {
+		return cl
+	}
+
+	return DefaultClient()
+}
+
+// AddReporter registers a reporter that every error reported with the client will be
+// sent to. Adding a reporter with the name of an existing one replaces it.
+func (cl *Client) AddReporter(name string, r Reporter) {
+	cl.reporters.add(name, r)
+}
+
+// RemoveReporter unregisters the reporter with the given name, if there is one
+func (cl *Client) RemoveReporter(name string) {
+	cl.reporters.remove(name)
+}
+
+// GetReporter returns the reporter registered with the given name, or nil if
+// there isn't one
+func (cl *Client) GetReporter(name string) Reporter {
+	return cl.reporters.get(name)
+}
+
+// SetFrameClasses replaces the rules used to classify stack frames
+func (cl *Client) SetFrameClasses(fc FrameClasses) {
+	cl.classRules.set(fc)
+}
+
+// AddStackRules adds rules that skip or truncate stack frames
+func (cl *Client) AddStackRules(rules ...StackRule) {
+	cl.stackRules.add(rules...)
+}
+
+// SetStackRules replaces all previously added rules, including the defaults. The
+// frames of this package's own wrapping functions are always skipped.
+func (cl *Client) SetStackRules(rules ...StackRule) {
+	cl.stackRules.set(rules...)
+}
+
+// SetLinkBuilder sets the link builder used for every frame. Passing nil disables links.
+func (cl *Client) SetLinkBuilder(lb LinkBuilder) {
+	cl.linkBuilderMu.Lock()
+	cl.linkBuilder = lb
+	cl.linkBuilderMu.Unlock()
+}
+
+// AddContextLabeler registers a labeler that runs on every report. When multiple
+// labelers set the same label, the one added last wins.
+func (cl *Client) AddContextLabeler(l ContextLabeler) {
+	cl.labelers.add(l)
+}
+
+// SetRuntimeStats sets when runtime statistics are captured and attached to reports.
+// Errors are wrapped before the client that reports them is known, so while any
+// client uses RuntimeStatsAtWrap every error captures them, and the other clients
+// replace or drop them when reporting.
+func (cl *Client) SetRuntimeStats(mode RuntimeStatsMode) {
+	prev := RuntimeStatsMode(atomic.SwapInt32(&cl.runtimeStatsMode, int32(mode)))
+	switch {
+	case prev != RuntimeStatsAtWrap && mode == RuntimeStatsAtWrap:
+		atomic.AddInt32(&wrapStatsClients, 1)
+	case prev == RuntimeStatsAtWrap && mode != RuntimeStatsAtWrap:
+		atomic.AddInt32(&wrapStatsClients, -1)
+	}
+}
+
+func (cl *Client) getRuntimeStatsMode() RuntimeStatsMode {
+	return RuntimeStatsMode(atomic.LoadInt32(&cl.runtimeStatsMode))
+}
+
+// SetScrubRules replaces the rules errors are scrubbed with before they're rendered
+// or reported, see DefaultScrubRules for the defaults
+func (cl *Client) SetScrubRules(sr ScrubRules) {
+	cl.scrub.set(sr)
+}
+
+// SetSampleRules replaces the rules the client throttles reports with
+func (cl *Client) SetSampleRules(rules ...SampleRule) {
+	cl.sampler.set(rules...)
+}
+
+// Close stops the client's report queue once it's drained, and then sends all errors
+// buffered by its reporters, or gives up when the context is done. Errors reported
+// after Close are sent synchronously, as if reported with Wait.
+func (cl *Client) Close(c context.Context) error {
+	if closeErr := cl.queue.close(c); closeErr != nil {
+		return errors.New("report queue did not drain: " + closeErr.Error())
+	}
+
+	return flushReporters(c, cl.reporters.list())
+}
+
+// Flush waits for the client's report queue to drain and then sends all errors
+// buffered by its reporters before returning, or until the context is done
+func (cl *Client) Flush(c context.Context) error {
+	if waitErr := cl.queue.wait(c); waitErr != nil {
+		return errors.New("report queue did not drain: " + waitErr.Error())
+	}
+
+	return flushReporters(c, cl.reporters.list())
+}
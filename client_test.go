@@ -0,0 +1,106 @@
+package e
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportWithClient(t *testing.T) {
+	defaultReporter := &testReporter{}
+	AddReporter("default", defaultReporter)
+	defer RemoveReporter("default")
+
+	r1, r2 := &testReporter{}, &testReporter{}
+	cl1 := NewClient(WithReporter("r", r1))
+	cl2 := NewClient(WithReporter("r", r2), WithLinkBuilder(nil))
+
+	c := context.Background()
+	New("default error").Report(c)
+	New("client 1 error").Report(c, ReportWithClient(cl1))
+	err2 := New("client 2 error")
+	err2.Report(c, ReportWithClient(cl2))
+	drain(DefaultClient())
+	drain(cl1)
+	drain(cl2)
+
+	assert.Len(t, defaultReporter.reported, 1)
+	assert.Len(t, r1.reported, 1)
+	assert.Len(t, r2.reported, 1)
+
+	// the client that reported the error renders it
+	assert.Equal(t, "", err2.sourceLink())
+	assert.NotContains(t, err2.Error(), "https://")
+
+	cl1.Flush(c)
+	assert.Equal(t, 1, r1.flushed)
+	assert.Equal(t, 0, r2.flushed)
+	assert.Equal(t, 0, defaultReporter.flushed)
+}
+
+func TestClientFrameClasses(t *testing.T) {
+	cl := NewClient(WithFrameClasses(FrameClasses{Vendor: []string{pkgPath}}))
+
+	err := New("some error")
+	err.Report(context.Background(), ReportWithClient(cl))
+
+	if assert.NotEmpty(t, err.frames) {
+		assert.EqualValues(t, classVendor, err.frames[0].class)
+	}
+
+	// the default client is unaffected
+	err = New("some error")
+	err.resolveFrames()
+	if assert.NotEmpty(t, err.frames) {
+		assert.EqualValues(t, classApp, err.frames[0].class)
+	}
+}
+
+func TestSetDefaultClient(t *testing.T) {
+	prev := DefaultClient()
+	defer SetDefaultClient(prev)
+
+	tr := &testReporter{}
+	SetDefaultClient(NewClient(WithReporter("r", tr)))
+	New("some error").Report(context.Background())
+	drain(DefaultClient())
+	assert.Len(t, tr.reported, 1)
+
+	SetDefaultClient(nil)
+	assert.NotNil(t, DefaultClient())
+	assert.Nil(t, GetReporter("r"))
+}
+
+func TestClientConcurrency(t *testing.T) {
+	// without a DSN, Sentry clients don't send anything
+	sentryCl, sentryErr := sentry.NewClient(sentry.ClientOptions{})
+	assert.NoError(t, sentryErr)
+
+	cl := NewClient()
+	c := context.Background()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			cl.SetSentryClient(c, sentryCl)
+			cl.SetLinkBuilder(VSCodeLinks())
+			cl.AddStackRules(NetHTTPStackRules()...)
+			cl.SetFrameClasses(DefaultFrameClasses())
+		}()
+		go func() {
+			defer wg.Done()
+			err := New("some error")
+			err.Report(c, ReportWithClient(cl))
+			_ = err.Error()
+		}()
+	}
+	wg.Wait()
+
+	_, ok := cl.GetReporter(SentryReporterName).(*SentryReporter)
+	assert.True(t, ok)
+}
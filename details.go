@@ -0,0 +1,344 @@
+package e
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+//
+// error details wrap options: added to the current frame and sent with GRPCStatus.
+// FieldViolation adds to a BadRequest, and RetryAfter sends a RetryInfo.
+//
+
+// ErrorInfo adds error details describing the cause of the error, where reason is a
+// constant in UPPER_SNAKE_CASE, domain is the service that generated it, and metadata
+// holds further dynamic information
+func ErrorInfo(reason, domain string, metadata map[string]string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		f.errDetails = append(f.errDetails, &errdetails.ErrorInfo{
+			Reason:   reason,
+			Domain:   domain,
+			Metadata: metadata,
+		})
+	}
+}
+
+// DebugInfo adds error details with debugging information, which shouldn't be
+// returned to untrusted clients. Since the details are always sent with GRPCStatus,
+// prefer Propagation's Frames, which is only sent to the callers it's enabled for.
+func DebugInfo(detail string, stackEntries ...string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		f.errDetails = append(f.errDetails, &errdetails.DebugInfo{
+			Detail:       detail,
+			StackEntries: stackEntries,
+		})
+	}
+}
+
+// QuotaViolation adds error details for a quota check that failed, e.g. subject
+// "project:123" and description "daily limit for read operations exceeded"
+func QuotaViolation(subject, description string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		violation := &errdetails.QuotaFailure_Violation{
+			Subject:     subject,
+			Description: description,
+		}
+
+		// look for an existing quota failure and add the violation to it
+		for _, detail := range f.errDetails {
+			if t, ok := detail.(*errdetails.QuotaFailure); ok {
+				t.Violations = append(t.Violations, violation)
+				return
+			}
+		}
+
+		f.errDetails = append(f.errDetails, &errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{violation},
+		})
+	}
+}
+
+// PreconditionViolation adds error details for a precondition that failed, where
+// typ is a service specific type such as "TOS", subject is what failed, e.g.
+// "google.com/cloud", and description how to fix it
+func PreconditionViolation(typ, subject, description string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		violation := &errdetails.PreconditionFailure_Violation{
+			Type:        typ,
+			Subject:     subject,
+			Description: description,
+		}
+
+		// look for an existing precondition failure and add the violation to it
+		for _, detail := range f.errDetails {
+			if t, ok := detail.(*errdetails.PreconditionFailure); ok {
+				t.Violations = append(t.Violations, violation)
+				return
+			}
+		}
+
+		f.errDetails = append(f.errDetails, &errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{violation},
+		})
+	}
+}
+
+// ResourceInfo adds error details describing the resource that was accessed
+func ResourceInfo(resourceType, resourceName, owner, description string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		f.errDetails = append(f.errDetails, &errdetails.ResourceInfo{
+			ResourceType: resourceType,
+			ResourceName: resourceName,
+			Owner:        owner,
+			Description:  description,
+		})
+	}
+}
+
+// RequestInfo adds error details with the id of the request, and any data needed
+// to debug it, e.g. a stack trace
+func RequestInfo(requestID, servingData string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		f.errDetails = append(f.errDetails, &errdetails.RequestInfo{
+			RequestId:   requestID,
+			ServingData: servingData,
+		})
+	}
+}
+
+// HelpLink adds error details with a link to documentation, e.g. on how to enable
+// an API or increase a quota
+func HelpLink(description, url string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		link := &errdetails.Help_Link{
+			Description: description,
+			Url:         url,
+		}
+
+		// look for an existing help and add the link to it
+		for _, detail := range f.errDetails {
+			if t, ok := detail.(*errdetails.Help); ok {
+				t.Links = append(t.Links, link)
+				return
+			}
+		}
+
+		f.errDetails = append(f.errDetails, &errdetails.Help{
+			Links: []*errdetails.Help_Link{link},
+		})
+	}
+}
+
+// LocalizedMessage adds error details with a message that is safe to show to the
+// user, where locale is a BCP 47 tag such as "en-US"
+func LocalizedMessage(locale, message string) WrapOption {
+	return func(err *Err) {
+		f := err.currentFrame()
+		f.errDetails = append(f.errDetails, &errdetails.LocalizedMessage{
+			Locale:  locale,
+			Message: message,
+		})
+	}
+}
+
+// DetailRenderer renders an error detail in Error() and in the Sentry extras. The
+// first line is its title, and the rest, if any, are indented with a tab.
+type DetailRenderer func(detail proto.Message) string
+
+// RegisterDetailRenderer sets how the default client renders error details of the
+// same type as detail, replacing the renderer of the google.rpc errdetails types.
+// Details without a renderer are rendered with their proto text format.
+func RegisterDetailRenderer(detail proto.Message, fn DetailRenderer) {
+	DefaultClient().RegisterDetailRenderer(detail, fn)
+}
+
+// WithDetailRenderer sets how the client renders error details of the same type as
+// detail, see RegisterDetailRenderer
+func WithDetailRenderer(detail proto.Message, fn DetailRenderer) ClientOption {
+	return func(cl *Client) {
+		cl.RegisterDetailRenderer(detail, fn)
+	}
+}
+
+// RegisterDetailRenderer sets how error details of the same type as detail are
+// rendered. Passing a nil renderer removes it.
+func (cl *Client) RegisterDetailRenderer(detail proto.Message, fn DetailRenderer) {
+	cl.detailRenderers.set(proto.MessageName(detail), fn)
+}
+
+// renderDetail renders a detail with its registered renderer, scrubbing the result
+func (cl *Client) renderDetail(detail proto.Message) string {
+	fn := cl.detailRenderers.get(proto.MessageName(detail))
+	if fn == nil {
+		return cl.scrub.str("Unknown ErrDetail: " + detail.String())
+	}
+
+	return cl.scrub.str(fn(detail))
+}
+
+// detailRendererSet maps the full proto names of details to their renderers. The map
+// is replaced rather than modified, so it can be read without holding the lock.
+type detailRendererSet struct {
+	mu        sync.RWMutex
+	renderers map[string]DetailRenderer
+}
+
+func newDetailRendererSet() *detailRendererSet {
+	drs := &detailRendererSet{
+		renderers: map[string]DetailRenderer{},
+	}
+
+	for detail, fn := range map[proto.Message]DetailRenderer{
+		&errdetails.BadRequest{}:          renderBadRequest,
+		&errdetails.ErrorInfo{}:           renderErrorInfo,
+		&errdetails.RetryInfo{}:           renderRetryInfo,
+		&errdetails.DebugInfo{}:           renderDebugInfo,
+		&errdetails.QuotaFailure{}:        renderQuotaFailure,
+		&errdetails.PreconditionFailure{}: renderPreconditionFailure,
+		&errdetails.ResourceInfo{}:        renderResourceInfo,
+		&errdetails.RequestInfo{}:         renderRequestInfo,
+		&errdetails.Help{}:                renderHelp,
+		&errdetails.LocalizedMessage{}:    renderLocalizedMessage,
+	} {
+		drs.renderers[proto.MessageName(detail)] = fn
+	}
+
+	return drs
+}
+
+func (drs *detailRendererSet) set(name string, fn DetailRenderer) {
+	drs.mu.Lock()
+	defer drs.mu.Unlock()
+
+	renderers := make(map[string]DetailRenderer, len(drs.renderers)+1)
+	for k, v := range drs.renderers {
+		renderers[k] = v
+	}
+
+	if fn == nil {
+		delete(renderers, name)
+	} else {
+		renderers[name] = fn
+	}
+
+	drs.renderers = renderers
+}
+
+func (drs *detailRendererSet) get(name string) DetailRenderer {
+	drs.mu.RLock()
+	defer drs.mu.RUnlock()
+
+	return drs.renderers[name]
+}
+
+// renderLines joins the title and the indented lines of a rendered detail
+func renderLines(title string, lines []string) string {
+	var sb strings.Builder
+	sb.WriteString(title)
+	for _, line := range lines {
+		sb.WriteString("\n\t")
+		sb.WriteString(line)
+	}
+
+	return sb.String()
+}
+
+func renderBadRequest(detail proto.Message) string {
+	t := detail.(*errdetails.BadRequest)
+	lines := make([]string, 0, len(t.FieldViolations))
+	for _, violation := range t.FieldViolations {
+		lines = append(lines, violation.Field+": "+violation.Description)
+	}
+
+	return renderLines("Bad Request:", lines)
+}
+
+func renderErrorInfo(detail proto.Message) string {
+	t := detail.(*errdetails.ErrorInfo)
+	lines := make([]string, 0, len(t.Metadata))
+	for k, v := range t.Metadata {
+		lines = append(lines, k+": "+v)
+	}
+	sort.Strings(lines)
+
+	return renderLines("Error Info: "+t.Reason+" ("+t.Domain+")", lines)
+}
+
+func renderRetryInfo(detail proto.Message) string {
+	t := detail.(*errdetails.RetryInfo)
+	return "Retry Info: retry after " + t.GetRetryDelay().AsDuration().String()
+}
+
+func renderDebugInfo(detail proto.Message) string {
+	t := detail.(*errdetails.DebugInfo)
+	return renderLines("Debug Info: "+t.Detail, t.StackEntries)
+}
+
+func renderQuotaFailure(detail proto.Message) string {
+	t := detail.(*errdetails.QuotaFailure)
+	lines := make([]string, 0, len(t.Violations))
+	for _, violation := range t.Violations {
+		lines = append(lines, violation.Subject+": "+violation.Description)
+	}
+
+	return renderLines("Quota Failure:", lines)
+}
+
+func renderPreconditionFailure(detail proto.Message) string {
+	t := detail.(*errdetails.PreconditionFailure)
+	lines := make([]string, 0, len(t.Violations))
+	for _, violation := range t.Violations {
+		lines = append(lines, violation.Type+" "+violation.Subject+": "+violation.Description)
+	}
+
+	return renderLines("Precondition Failure:", lines)
+}
+
+func renderResourceInfo(detail proto.Message) string {
+	t := detail.(*errdetails.ResourceInfo)
+	var lines []string
+	if t.Owner != "" {
+		lines = append(lines, "owner: "+t.Owner)
+	}
+	if t.Description != "" {
+		lines = append(lines, t.Description)
+	}
+
+	return renderLines("Resource Info: "+t.ResourceType+" "+t.ResourceName, lines)
+}
+
+func renderRequestInfo(detail proto.Message) string {
+	t := detail.(*errdetails.RequestInfo)
+	var lines []string
+	if t.ServingData != "" {
+		lines = append(lines, t.ServingData)
+	}
+
+	return renderLines("Request Info: "+t.RequestId, lines)
+}
+
+func renderHelp(detail proto.Message) string {
+	t := detail.(*errdetails.Help)
+	lines := make([]string, 0, len(t.Links))
+	for _, link := range t.Links {
+		lines = append(lines, link.Description+": "+link.Url)
+	}
+
+	return renderLines("Help:", lines)
+}
+
+func renderLocalizedMessage(detail proto.Message) string {
+	t := detail.(*errdetails.LocalizedMessage)
+	return "Localized Message (" + t.Locale + "): " + t.Message
+}
@@ -0,0 +1,77 @@
+package e
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDetailWrapOptions(t *testing.T) {
+	err := New("failed",
+		ErrorInfo("API_DISABLED", "acme.com", map[string]string{"service": "pubsub", "project": "123"}),
+		DebugInfo("nil pointer", "main.go:12", "main.go:34"),
+		QuotaViolation("project:123", "daily limit exceeded"),
+		QuotaViolation("user:abc", "rate limit exceeded"),
+		PreconditionViolation("TOS", "acme.com/cloud", "terms of service not accepted"),
+		ResourceInfo("sql table", "users", "user:admin", "not found"),
+		RequestInfo("req-1", "served by db-2"),
+		HelpLink("enable the API", "https://acme.com/enable"),
+		LocalizedMessage("en-US", "Something went wrong"),
+	)
+
+	details := err.Details()
+	require.Len(t, details, 8)
+	assert.Len(t, details[2].(*errdetails.QuotaFailure).Violations, 2)
+
+	// every type has a readable rendering
+	msg := err.Error()
+	for _, want := range []string{
+		" + Error Info: API_DISABLED (acme.com)\n\tproject: 123\n\tservice: pubsub",
+		" + Debug Info: nil pointer\n\tmain.go:12\n\tmain.go:34",
+		" + Quota Failure:\n\tproject:123: daily limit exceeded\n\tuser:abc: rate limit exceeded",
+		" + Precondition Failure:\n\tTOS acme.com/cloud: terms of service not accepted",
+		" + Resource Info: sql table users\n\towner: user:admin\n\tnot found",
+		" + Request Info: req-1\n\tserved by db-2",
+		" + Help:\n\tenable the API: https://acme.com/enable",
+		" + Localized Message (en-US): Something went wrong",
+	} {
+		assert.Contains(t, msg, want)
+	}
+	assert.NotContains(t, msg, "Unknown ErrDetail")
+
+	// all of them are sent with the status
+	assert.Len(t, err.GRPCStatus().Details(), 8)
+}
+
+func TestRegisterDetailRenderer(t *testing.T) {
+	cl := NewClient()
+
+	detail := wrapperspb.String("custom")
+	err := New("failed", func(err *Err) {
+		f := err.currentFrame()
+		f.errDetails = append(f.errDetails, detail, &errdetails.BadRequest{})
+	}, FieldViolation("id", "is required"))
+	err.resolveFrames()
+
+	assert.True(t, strings.HasPrefix(cl.renderDetail(detail), "Unknown ErrDetail: "))
+	assert.Equal(t, "Bad Request:\n\tid: is required", cl.renderDetail(err.Details()[1]))
+
+	cl.RegisterDetailRenderer(&wrapperspb.StringValue{}, func(detail proto.Message) string {
+		return "String: " + detail.(*wrapperspb.StringValue).GetValue()
+	})
+	assert.Equal(t, "String: custom", cl.renderDetail(detail))
+	assert.Contains(t, err.frames[0].string(cl), "\n + String: custom\n")
+
+	// the rendered details are sent as sentry extras
+	err.setClient(cl)
+	assert.Equal(t, []string{"String: custom", "Bad Request:\n\tid: is required"}, err.sentryExtra()["errDetails"])
+
+	// removing the renderer falls back to the text format
+	cl.RegisterDetailRenderer(&wrapperspb.StringValue{}, nil)
+	assert.True(t, strings.HasPrefix(cl.renderDetail(detail), "Unknown ErrDetail: "))
+}
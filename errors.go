@@ -4,14 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/getsentry/sentry-go"
 	"github.com/golang/protobuf/proto"
-	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -27,12 +28,21 @@ var (
 type Err struct {
 	rootErr error
 
-	// raw data captured at the moment the error was initially wrapped
-	rawStack []byte
-	memStats *runtime.MemStats
-
-	// frames that weren't skipped/truncated from the rawStack parse
-	frames stackframes
+	// raw data captured at the moment the error was initially wrapped. rawStack
+	// is only set if it was provided with the Stack option
+	pcs          []uintptr
+	rawStack     []byte
+	runtimeStats *runtimeStats
+
+	// frames that weren't skipped/truncated from the pcs or rawStack parse. These
+	// are resolved lazily, so they should only be accessed after resolveFrames.
+	// Until then, frame scoped options from the initial wrap go to pendingFrame.
+	// framesMu guards the resolution, as errors are often logged and reported from
+	// several goroutines at once.
+	frames         stackframes
+	framesMu       sync.Mutex
+	framesResolved bool
+	pendingFrame   *frame
 	// if findFrame fails, frame data is stored here. This typically
 	// only happens when wrapping across goroutine boundaries, as that
 	// leaves the
@@ -40,34 +50,57 @@ type Err struct {
 	currentFrameIdx int
 	topAppFrame     *frame
 
+	// frames of the server that returned the error, decoded from its gRPC status
+	remoteFrames stackframes
+
+	// independently wrapped errors joined into this one, each with its own frames
+	children []*Err
+
 	// contextual data set from WrapOptions
 	Level       Level
 	code        codes.Code
 	tags        map[string]string
 	isRetriable bool
+	retryAfter  time.Duration
 	noReport    bool
 	isPanic     bool
 	isInfra     bool
+	isRemote    bool
 	skipFrames  int
 
 	// contextual data set from ReportOptions
 	fromHandler  bool
 	shouldWait   bool
 	sentryClient *sentry.Client
+	// set by ReportWithClient while the error may be rendered by other goroutines
+	client atomic.Pointer[Client]
+
+	// labels extracted from the context passed to Report
+	labels Labels
+
+	// set to 1 once the error has been reported, so the SlogHandler doesn't report it again
+	reported int32
+
+	// parts the error is grouped by, overriding the default, see Fingerprint
+	fingerprint []string
+
+	// number of similar reports suppressed by the sample rules before this one
+	suppressed int
 }
 
 type stackframes []*frame
 
 type frame struct {
-	msg   string
-	file  string
-	path  string
-	pkg   string
-	fn    string
-	full  string
-	line  int
-	class class
-	vars  map[string]interface{}
+	msg     string
+	file    string
+	absPath string
+	path    string
+	pkg     string
+	fn      string
+	full    string
+	line    int
+	class   class
+	vars    map[string]interface{}
 	// errDetails contains proto messages containing detailed descriptions
 	// ex. errdetails.FieldViolations, errdetails.Help, errdetails.PreconditionFailure.
 	errDetails []proto.Message
@@ -88,6 +121,24 @@ const (
 	classPanic = 5
 )
 
+// String fulfills the Stringer interface
+func (c class) String() string {
+	switch c {
+	case classApp:
+		return "app"
+	case classPkg:
+		return "pkg"
+	case classVendor:
+		return "vendor"
+	case classStdLib:
+		return "stdlib"
+	case classPanic:
+		return "panic"
+	default:
+		return ""
+	}
+}
+
 // Level represents an error severity level
 type Level int
 
@@ -100,6 +151,20 @@ const (
 	LevelWarning Level = 3
 )
 
+// String fulfills the Stringer interface
+func (l Level) String() string {
+	switch l {
+	case LevelCritical:
+		return "critical"
+	case LevelError:
+		return "error"
+	case LevelWarning:
+		return "warning"
+	default:
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
 // String fulfills the Stringer interface
 func (err *Err) String() string {
 	return err.Error()
@@ -115,7 +180,8 @@ type unwrapper interface {
 
 // Cause returns the root cause of the error without the extra wrapped data.
 // Supports our own errors, Dave Cheney's pkg/errors, and anything that implements
-// either the Cause() error or Unwrap() error interface.
+// either the Cause() error or Unwrap() error interface. Errors with several causes,
+// such as those from Join, are returned as they are, use Children to get the causes.
 func Cause(rawErr error) error {
 	switch err := rawErr.(type) {
 	case *Err:
@@ -136,6 +202,8 @@ func (err *Err) Unwrap() error {
 
 // Error fulfills the error interface
 func (err *Err) Error() string {
+	err.resolveFrames()
+
 	buf := getBuffer()
 	defer putBuffer(buf)
 
@@ -148,8 +216,10 @@ func (err *Err) Error() string {
 	buf.WriteByte('\n')
 
 	// print out a link to where the error occurred
-	buf.WriteString(err.githubURL())
-	buf.WriteByte('\n')
+	if link := err.sourceLink(); link != "" {
+		buf.WriteString(link)
+		buf.WriteByte('\n')
+	}
 	buf.WriteByte('\n')
 
 	// callout that this task isn't retriable
@@ -158,28 +228,47 @@ func (err *Err) Error() string {
 	}
 
 	// print out our organized stack trace with any added context
-	buf.WriteString(err.frames.String())
+	buf.WriteString(err.frames.string(err.cl()))
 
 	// extra reporting if unknown frames were found
 	if len(err.unknownFrames) > 0 {
 		buf.WriteString("\n\n--- UNKNOWN STACK FRAMES FOUND ---")
-		buf.WriteString(err.unknownFrames.String())
+		buf.WriteString(err.unknownFrames.string(err.cl()))
+	}
+
+	// the frames of the server come after ours, since that's where the error occurred
+	if len(err.remoteFrames) > 0 {
+		buf.WriteString("\n\n--- REMOTE STACK FRAMES ---")
+		buf.WriteString(err.remoteFrames.string(err.cl()))
 	}
 
-	// spew out the full error with type info if it is anything but errors.New()
+	// every joined error has its own report
+	for i, child := range err.children {
+		buf.WriteString(fmt.Sprintf("\n=== JOINED ERROR %d OF %d ===\n", i+1, len(err.children)))
+		buf.WriteString(child.Error())
+	}
+
+	// spew out the full error with type info if it is anything but errors.New(),
+	// unless it's made of children that were already printed
 	errStr := fmt.Sprintf("%#v", err.rootErr)
-	if !strings.HasPrefix(errStr, "&errors.errorString") {
+	if len(err.children) == 0 && !strings.HasPrefix(errStr, "&errors.errorString") {
 		buf.WriteString("\nErr: ")
-		buf.WriteString(spew.Sdump(err.rootErr))
+		buf.WriteString(err.cl().scrub.str(spew.Sdump(err.rootErr)))
 	}
 
 	return buf.String()
 }
 
-// Location returns the path/file/line where the first New/Wrap happened
+// Location returns the path/file/line where the first New/Wrap happened. The Err
+// is found anywhere in the chain of rawErr.
 func Location(rawErr error) (string, string, int) {
-	err, ok := rawErr.(*Err)
-	if !ok || len(err.frames) == 0 {
+	err, ok := asErr(rawErr)
+	if !ok {
+		return "", "", 0
+	}
+
+	err.resolveFrames()
+	if len(err.frames) == 0 {
 		return "", "", 0
 	}
 
@@ -225,14 +314,14 @@ func (err *Err) Code() codes.Code {
 	return err.code
 }
 
-// CodeFromError returns the error code from an error if there is one.
-// Returns OK if err is nil and Unknown if it isn't a wrapped error.
+// CodeFromError returns the error code from an error if there is one, finding the
+// Err anywhere in the chain. Returns OK if err is nil and Unknown if it isn't a wrapped error.
 func CodeFromError(err error) codes.Code {
 	if err == nil {
 		return codes.OK
 	}
 
-	typedErr, ok := err.(*Err)
+	typedErr, ok := asErr(err)
 	if !ok {
 		return codes.Unknown
 	}
@@ -245,44 +334,91 @@ func (err *Err) IsRetriable() bool {
 	return err.isRetriable
 }
 
-// githubURL returns a direct link to the line where the first New/Wrap happened
-// https://github.com/nozzle/nozzle/blob/ab15cc7c4cf7ed21fcaa8957ef390dbdbc7e1816/namespaces/publish/rankings_client.go#L11
-func (err *Err) githubURL() string {
+// IsRetriable returns whether or not a retry might succeed, finding the Err anywhere
+// in the chain. Returns false if err is nil and true if it isn't a wrapped error,
+// since errors are retriable unless marked otherwise.
+func IsRetriable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	typedErr, ok := asErr(err)
+	if !ok {
+		return true
+	}
+
+	return typedErr.isRetriable
+}
+
+// RetryAfter returns how long to wait before retrying, if it was set with RetryAfter
+func (err *Err) RetryAfter() (time.Duration, bool) {
+	return err.retryAfter, err.retryAfter > 0
+}
+
+// RetryAfterFromError returns how long to wait before retrying, finding the Err
+// anywhere in the chain. The bool is false if there's no Err or it wasn't set.
+func RetryAfterFromError(err error) (time.Duration, bool) {
+	typedErr, ok := asErr(err)
+	if !ok {
+		return 0, false
+	}
+
+	return typedErr.RetryAfter()
+}
+
+// sourceLink returns a direct link to the line where the first New/Wrap happened
+func (err *Err) sourceLink() string {
+	err.resolveFrames()
 	if len(err.frames) == 0 {
 		return ""
 	}
 
-	buf := getBuffer()
-	defer putBuffer(buf)
+	return err.cl().link(err.frames[0])
+}
+
+func (err *Err) currentFrame() *frame {
+	err.framesMu.Lock()
+	resolved := err.framesResolved
+	err.framesMu.Unlock()
+
+	// options on the initial wrap don't need the stack to be resolved yet
+	if !resolved && err.currentFrameIdx == 0 {
+		if err.pendingFrame == nil {
+			err.pendingFrame = &frame{}
+		}
+		return err.pendingFrame
+	}
 
-	d := err.frames[0]
-	buf.WriteString("https://github.com/nozzle/e/blob/")
-	buf.WriteString("main")
-	buf.WriteString(strings.TrimPrefix(d.path, "github.com/nozzle/e"))
-	buf.WriteByte('/')
-	buf.WriteString(d.file)
-	buf.WriteString("#L")
-	buf.WriteString(strconv.Itoa(d.line))
+	err.resolveFrames()
 
-	return buf.String()
+	return err.resolvedFrame()
 }
 
-func (err *Err) currentFrame() *frame {
+// resolvedFrame returns the current frame of an error whose frames are resolved
+func (err *Err) resolvedFrame() *frame {
 	// don't panic if the current frame is unknown from the stack parse
-	if err.currentFrameIdx == -1 {
+	if err.currentFrameIdx == -1 || len(err.frames) == 0 {
+		if len(err.unknownFrames) == 0 {
+			err.unknownFrames = append(err.unknownFrames, &frame{})
+		}
 		return err.unknownFrames[len(err.unknownFrames)-1]
 	}
 
+	// the index can run past the stack if it was wrapped more times than it has frames
+	if err.currentFrameIdx >= len(err.frames) {
+		return err.frames[len(err.frames)-1]
+	}
+
 	return err.frames[err.currentFrameIdx]
 }
 
-func (fs stackframes) String() string {
+func (fs stackframes) string(cl *Client) string {
 	buf := getBuffer()
 	defer putBuffer(buf)
 
 	buf.WriteString("-------------------------------\n")
 	for i := range fs {
-		buf.WriteString(fs[i].String())
+		buf.WriteString(fs[i].string(cl))
 		buf.WriteString("\n")
 	}
 	return buf.String()
@@ -290,14 +426,14 @@ func (fs stackframes) String() string {
 
 const maxLogStringLen = 500
 
-func (f frame) String() string {
+func (f *frame) string(cl *Client) string {
 	buf := getBuffer()
 	defer putBuffer(buf)
 
 	// write the user provided message if there is one
 	if f.msg != "" {
 		buf.WriteString("*** ")
-		buf.WriteString(f.msg)
+		buf.WriteString(cl.scrub.str(f.msg))
 		buf.WriteByte('\n')
 	}
 
@@ -305,8 +441,12 @@ func (f frame) String() string {
 	// e.g. errors_test.go:103 - TestRecursiveWrap.func1
 	buf.WriteString(fmt.Sprintf("%s.%s", f.pkg, f.fn))
 	buf.WriteString(fmt.Sprintf("\n %s/%s:%d", f.path, f.file, f.line))
+	if link := cl.link(f); link != "" {
+		buf.WriteString("\n ")
+		buf.WriteString(link)
+	}
 
-	for k, v := range f.vars {
+	for k, v := range cl.scrub.vars(f.vars) {
 		switch t := v.(type) {
 		case fmt.Stringer:
 			str := t.String()
@@ -337,18 +477,8 @@ func (f frame) String() string {
 
 	// if we have error details include those in the message
 	for _, detail := range f.errDetails {
-		buf.WriteByte('\n')
-		switch t := detail.(type) {
-		case *errdetails.BadRequest:
-			buf.WriteString(" + Bad Request:")
-			for _, violation := range t.FieldViolations {
-				buf.WriteString(fmt.Sprintf("\n\t%s: %s", violation.Field, violation.Description))
-			}
-
-		default:
-			buf.WriteString(" + Unknown ErrDetail: ")
-			buf.WriteString(t.String())
-		}
+		buf.WriteString("\n + ")
+		buf.WriteString(cl.renderDetail(detail))
 	}
 
 	buf.WriteString("\n-------------------------------")
@@ -0,0 +1,61 @@
+package e
+
+import (
+	"regexp"
+)
+
+var (
+	fingerprintUUIDRe = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	fingerprintIDRe   = regexp.MustCompile(`[\w-]*\d[\w-]*`)
+)
+
+// Fingerprint overrides the parts errors are grouped by, e.g. Fingerprint("billing",
+// "stripe-timeout") groups every error wrapped with it into a single issue. The last
+// call wins.
+// This is error scoped, not frame scoped.
+func Fingerprint(parts ...string) WrapOption {
+	return func(err *Err) {
+		err.fingerprint = parts
+	}
+}
+
+// FingerprintFromError returns the parts errors similar to err are grouped by, see
+// (*Err).Fingerprint. Errors that aren't Errs are grouped by their normalized message.
+func FingerprintFromError(rawErr error) []string {
+	if rawErr == nil {
+		return nil
+	}
+
+	if err, ok := asErr(rawErr); ok {
+		return err.Fingerprint()
+	}
+
+	return []string{normalizeMessage(rawErr.Error())}
+}
+
+// Fingerprint returns the parts the error is grouped by when it's reported. Unless
+// they're overridden with the Fingerprint WrapOption, these are the function of the
+// top app frame, the code and the root error message with UUIDs, numbers and other
+// identifiers replaced, so that errors naming different users or orders end up in
+// the same group.
+func (err *Err) Fingerprint() []string {
+	if len(err.fingerprint) > 0 {
+		return err.fingerprint
+	}
+
+	err.resolveFrames()
+
+	var fn string
+	if f := err.topAppFrame; f != nil {
+		fn = f.full
+	}
+
+	return []string{fn, err.code.String(), normalizeMessage(err.rootErr.Error())}
+}
+
+// normalizeMessage replaces UUIDs with <uuid> and every word containing a digit, like
+// numbers, hex IDs and timestamps, with <id>
+func normalizeMessage(msg string) string {
+	msg = fingerprintUUIDRe.ReplaceAllString(msg, "<uuid>")
+	return fingerprintIDRe.ReplaceAllString(msg, "<id>")
+}
@@ -0,0 +1,91 @@
+package e
+
+import (
+	"context"
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func fingerprintHelper(id string) *Err {
+	return New(fmt.Sprintf("order %s of user 42 not found", id), Code(codes.NotFound))
+}
+
+func TestFingerprint(t *testing.T) {
+	err1 := fingerprintHelper("3f2504e0-4f89-11d3-9a0c-0305e82c3301")
+	err2 := fingerprintHelper("a1b2c3")
+
+	assert.Equal(t, []string{
+		"github.com/nozzle/e.fingerprintHelper",
+		"NotFound",
+		"order <uuid> of user <id> not found",
+	}, err1.Fingerprint())
+	assert.Equal(t, "order <id> of user <id> not found", err2.Fingerprint()[2])
+
+	// wrapping elsewhere doesn't change the top app frame
+	wrapped := fmt.Errorf("handling: %w", Wrap(err1, Msg("loading")))
+	assert.Equal(t, err1.Fingerprint(), FingerprintFromError(wrapped))
+
+	// the last override wins
+	overridden := Wrap(fingerprintHelper("1"), Fingerprint("orders"), Fingerprint("orders", "not-found"))
+	assert.Equal(t, []string{"orders", "not-found"}, overridden.Fingerprint())
+
+	assert.Equal(t, []string{"timeout after <id>"}, FingerprintFromError(errors.New("timeout after 30s")))
+	assert.Nil(t, FingerprintFromError(nil))
+}
+
+func TestFingerprintReport(t *testing.T) {
+	err := Wrap(fingerprintHelper("1"), Fingerprint("orders"))
+	assert.Equal(t, []string{"orders"}, err.sentryEvent(context.Background()).Fingerprint)
+
+	b, marshalErr := json.Marshal(err)
+	require.NoError(t, marshalErr)
+
+	got := &Err{}
+	require.NoError(t, json.Unmarshal(b, got))
+	assert.Equal(t, []string{"orders"}, got.Fingerprint())
+}
+
+func TestErrorReportingGrouping(t *testing.T) {
+	newUserErr := func(id string) *Err {
+		return New("user " + id + " not found")
+	}
+	a, b, other := newUserErr("123"), newUserErr("456"), New("other")
+	elsewhere := New("user 789 not found", Fingerprint("user not found"))
+	sameStack := func(fingerprint string) *Err {
+		return New("user not found", Fingerprint(fingerprint))
+	}
+
+	// by default the message and stack are sent as they are
+	r := &ErrorReportingReporter{}
+	entry := r.entry(a)
+	assert.Equal(t, a.rootErr, entry.Error)
+	assert.Equal(t, a.stackTrace(), entry.Stack)
+
+	// Error Reporting groups by the top frame, so it has to be the same for errors
+	// with the same fingerprint, and only for them
+	r.GroupByFingerprint = true
+	topFrame := func(err *Err) string {
+		entry := r.entry(err)
+		assert.Equal(t, err.rootErr, entry.Error)
+
+		// the header and the real stack are kept around the top frame
+		lines := strings.SplitN(string(entry.Stack), "\n", 4)
+		require.Len(t, lines, 4)
+		assert.Equal(t, "goroutine 1 [running]:", lines[0])
+		assert.True(t, strings.HasSuffix(string(entry.Stack), string(err.stackTrace())[len(lines[0])+1:]))
+
+		return lines[1] + "\n" + lines[2]
+	}
+	assert.Equal(t, topFrame(a), topFrame(b))
+	assert.NotEqual(t, topFrame(a), topFrame(other))
+	assert.NotEqual(t, topFrame(a), topFrame(elsewhere))
+	assert.Equal(t, topFrame(elsewhere), topFrame(New("user 0 not found", Fingerprint("user not found"))))
+	assert.NotEqual(t, topFrame(sameStack("a")), topFrame(sameStack("b")))
+}
@@ -0,0 +1,141 @@
+package e
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var _ fmt.Formatter = (*Err)(nil)
+
+// Format fulfills the fmt.Formatter interface, so callers choose how much they see:
+//
+//	%s, %v  the root error message, prefixed by the Msg of every wrap, outermost first
+//	%q      the same as %s, but quoted
+//	%+v     the full report returned by Error()
+//	%#v     a structured debug dump of the error's fields and frames
+func (err *Err) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		io.WriteString(s, err.Error()) //nolint:errcheck
+
+	case verb == 'v' && s.Flag('#'):
+		io.WriteString(s, err.goString()) //nolint:errcheck
+
+	case verb == 'v', verb == 's':
+		io.WriteString(s, err.shortString()) //nolint:errcheck
+
+	case verb == 'q':
+		io.WriteString(s, strconv.Quote(err.shortString())) //nolint:errcheck
+
+	default:
+		fmt.Fprintf(s, "%%!%c(*e.Err=%s)", verb, err.shortString())
+	}
+}
+
+// shortString returns the root error message prefixed by the scrubbed frame messages,
+// starting with the outermost wrap
+func (err *Err) shortString() string {
+	if msgs := err.frameMsgs(); msgs != "" {
+		return err.cl().scrub.str(msgs) + ": " + err.rootErr.Error()
+	}
+
+	return err.rootErr.Error()
+}
+
+// frameMsgs joins the frame messages, starting with the outermost wrap
+func (err *Err) frameMsgs() string {
+	return strings.Join(err.frameMsgList(), ": ")
+}
+
+// frameMsgList returns the non-empty frame messages, starting with the outermost wrap
+func (err *Err) frameMsgList() []string {
+	var msgs []string
+	writeMsg := func(msg string) {
+		if msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	// unknown frames are appended as the error is wrapped, so the last one is the outermost
+	for i := len(err.unknownFrames) - 1; i >= 0; i-- {
+		writeMsg(err.unknownFrames[i].msg)
+	}
+
+	// options from the initial wrap are held by the pending frame until the stack is resolved
+	if pf := err.pendingFrame; pf != nil {
+		writeMsg(pf.msg)
+		return msgs
+	}
+
+	// frames are stored top down, so the last one is the outermost
+	for i := len(err.frames) - 1; i >= 0; i-- {
+		writeMsg(err.frames[i].msg)
+	}
+
+	return msgs
+}
+
+// goString returns a single line dump of the error in a Go-like syntax
+func (err *Err) goString() string {
+	err.resolveFrames()
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	fmt.Fprintf(buf, "&e.Err{rootErr:%q, code:%s, Level:%s, isRetriable:%t, noReport:%t, isPanic:%t, isInfra:%t",
+		err.rootErr.Error(), err.code, err.Level, err.isRetriable, err.noReport, err.isPanic, err.isInfra)
+
+	cl := err.cl()
+	if len(err.tags) > 0 {
+		fmt.Fprintf(buf, ", tags:%#v", cl.scrub.tags(err.tags))
+	}
+
+	writeFrames := func(name string, fs stackframes) {
+		if len(fs) == 0 {
+			return
+		}
+
+		buf.WriteString(", ")
+		buf.WriteString(name)
+		buf.WriteString(":[")
+		for i, f := range fs {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			f.writeGoString(buf, cl.scrub)
+		}
+		buf.WriteByte(']')
+	}
+
+	writeFrames("frames", err.frames)
+	writeFrames("unknownFrames", err.unknownFrames)
+	writeFrames("remoteFrames", err.remoteFrames)
+
+	buf.WriteByte('}')
+
+	return buf.String()
+}
+
+func (f *frame) writeGoString(w io.Writer, s *scrubber) {
+	fmt.Fprintf(w, "{fn:%q, loc:\"%s/%s:%d\", class:%s", f.full, f.path, f.file, f.line, f.class)
+
+	if f.msg != "" {
+		fmt.Fprintf(w, ", msg:%q", s.str(f.msg))
+	}
+
+	if len(f.vars) > 0 {
+		fmt.Fprintf(w, ", vars:%#v", s.vars(f.vars))
+	}
+
+	if len(f.errDetails) > 0 {
+		details := make([]string, 0, len(f.errDetails))
+		for _, detail := range f.errDetails {
+			details = append(details, detail.String())
+		}
+		fmt.Fprintf(w, ", errDetails:[%s]", strings.Join(details, ", "))
+	}
+
+	io.WriteString(w, "}") //nolint:errcheck
+}
@@ -0,0 +1,47 @@
+package e
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestFormat(t *testing.T) {
+	err := formatHelper()
+
+	assert.Equal(t, "outer: inner: some error", fmt.Sprintf("%v", err))
+	assert.Equal(t, "outer: inner: some error", fmt.Sprintf("%s", err))
+	assert.Equal(t, `"outer: inner: some error"`, fmt.Sprintf("%q", err))
+	assert.Equal(t, err.Error(), fmt.Sprintf("%+v", err))
+	assert.Equal(t, "loading: outer: inner: some error", fmt.Errorf("loading: %w", err).Error())
+
+	dump := fmt.Sprintf("%#v", err)
+	assert.True(t, strings.HasPrefix(dump, `&e.Err{rootErr:"some error", code:NotFound, Level:error, isRetriable:true, noReport:false, isPanic:false, isInfra:false, tags:map[string]string{"k":"v"}, frames:[{fn:"github.com/nozzle/e.formatHelperInner", loc:"github.com/nozzle/e/format_test.go:`), dump)
+	assert.Contains(t, dump, `class:app, msg:"inner", vars:map[string]interface {}{"id":1}}`)
+	assert.Contains(t, dump, `{fn:"github.com/nozzle/e.formatHelper", loc:"github.com/nozzle/e/format_test.go:`)
+	assert.NotContains(t, dump, "\n")
+}
+
+func formatHelper() *Err {
+	return Wrap(formatHelperInner(), Msg("outer"))
+}
+
+func formatHelperInner() error {
+	return Wrap(errors.New("some error"), Msg("inner"), With("id", 1), Tag("k", "v"), Code(codes.NotFound))
+}
+
+func TestFormatPendingFrame(t *testing.T) {
+	// wraps that weren't found in the stack are shown before the stack is resolved
+	err := New("some error", Msg("inner"))
+	err.unknownFrames = append(err.unknownFrames, &frame{msg: "outer"})
+	require.NotNil(t, err.pendingFrame)
+	assert.Equal(t, "outer: inner: some error", err.shortString())
+
+	err.resolveFrames()
+	assert.Equal(t, "outer: inner: some error", err.shortString())
+}
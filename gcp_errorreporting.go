@@ -1,25 +1,103 @@
 package e
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
 
 	er "cloud.google.com/go/errorreporting"
 )
 
-var errorClient *er.Client
+// ErrorReportingReporter reports errors to Google Cloud Error Reporting
+type ErrorReportingReporter struct {
+	Client *er.Client
 
-// SetStackdriverErrorReportingClient enables reporting to GCP.
-// Should only be called once at app startup.
+	// GroupByFingerprint groups the errors like Sentry does, see Fingerprint. Error
+	// Reporting can't be given a fingerprint, and groups by the top frames of the stack
+	// instead, so this adds a fake frame named after the fingerprint to the top of every
+	// stack, which is shown in the Error Reporting console. It relies on undocumented
+	// grouping behavior, so it's off by default.
+	GroupByFingerprint bool
+}
+
+// SetStackdriverErrorReportingClient enables reporting to GCP by registering an
+// ErrorReportingReporter on the default client. Should only be called once at app startup.
 func SetStackdriverErrorReportingClient(c context.Context, cl *er.Client) error {
-	errorClient = cl
+	return DefaultClient().SetStackdriverErrorReportingClient(c, cl)
+}
+
+// SetStackdriverErrorReportingClient enables reporting to GCP by registering an
+// ErrorReportingReporter on the client
+func (cl *Client) SetStackdriverErrorReportingClient(c context.Context, erCl *er.Client) error {
+	cl.AddReporter(ErrorReportingReporterName, &ErrorReportingReporter{Client: erCl})
+	return nil
+}
+
+// Report fulfills the Reporter interface
+func (r *ErrorReportingReporter) Report(c context.Context, err *Err) error {
+	if r.Client == nil {
+		return errors.New("no Error Reporting client set")
+	}
+
+	r.Client.Report(r.entry(err))
+
 	return nil
 }
 
-func (err *Err) reportToStackdriver(c context.Context) {
-	entry := er.Entry{
+func (r *ErrorReportingReporter) entry(err *Err) er.Entry {
+	stack := err.stackTrace()
+	if r.GroupByFingerprint {
+		stack = fingerprintStack(err.Fingerprint(), stack)
+	}
+
+	return er.Entry{
 		Error: err.rootErr,
-		// Req:   internal.Request(c),
-		Stack: err.rawStack,
+		Req:   err.cl().scrub.httpRequest(err.labels.Request),
+		User:  err.labels.UserID,
+		Stack: stack,
 	}
-	errorClient.Report(entry)
+}
+
+// Flush fulfills the Reporter interface. The Error Reporting client can't be
+// interrupted, so it keeps flushing in the background if the context is done first.
+func (r *ErrorReportingReporter) Flush(c context.Context) error {
+	if r.Client == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.Client.Flush()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// fingerprintStack adds a frame named after the fingerprint to the top of a stack in the
+// format of debug.Stack, see GroupByFingerprint
+func fingerprintStack(fingerprint []string, stack []byte) []byte {
+	h := fnv.New64a()
+	io.WriteString(h, strings.Join(fingerprint, "\x00")) //nolint:errcheck
+
+	// the frame goes right after the goroutine header
+	header, calls, ok := bytes.Cut(stack, []byte("\n"))
+	if !ok {
+		return stack
+	}
+
+	buf := make([]byte, 0, len(stack)+64)
+	buf = append(buf, header...)
+	buf = append(buf, '\n')
+	buf = append(buf, fmt.Sprintf("%s.fingerprint_%x(...)\n\tfingerprint:1 +0x0\n", pkgPath, h.Sum64())...)
+	return append(buf, calls...)
 }
@@ -1,6 +1,6 @@
 module github.com/nozzle/e
 
-go 1.18
+go 1.21
 
 require (
 	cloud.google.com/go/errorreporting v0.2.0
@@ -15,6 +15,7 @@ require (
 	google.golang.org/api v0.78.0
 	google.golang.org/genproto v0.0.0-20220505152158-f39f71e6c8f3
 	google.golang.org/grpc v1.46.0
+	google.golang.org/protobuf v1.28.0
 )
 
 require (
@@ -32,6 +33,5 @@ require (
 	golang.org/x/sys v0.0.0-20220503163025-988cb79eb6c6 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
-	google.golang.org/protobuf v1.28.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
@@ -5,18 +5,39 @@ import (
 	"log"
 
 	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
-// GRPCStatus converts the error into a grpc compatible status
+// GRPCStatus converts the error into a grpc compatible status. The delay set with
+// RetryAfter is added as an errdetails.RetryInfo, unless the details already have one.
+// Frames and semantics are never added, since the caller isn't known, see ServerPropagation.
 func (err *Err) GRPCStatus() *status.Status {
+	return err.grpcStatus(Propagation{})
+}
+
+// grpcStatus converts the error into a status, adding what is propagated to the caller
+func (err *Err) grpcStatus(propagation Propagation) *status.Status {
 	// if originating error is a status.Status return that otherwise create a new one
 	st, ok := status.FromError(err.rootErr)
 	if !ok {
 		st = status.New(err.code, err.rootErr.Error())
 	}
 
-	stWithDetails, withDetailErr := st.WithDetails(err.Details()...)
+	details := err.Details()
+	if err.retryAfter > 0 && !hasRetryInfo(st, details) {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(err.retryAfter)})
+	}
+
+	if propagation.Frames {
+		details = append(details, err.framesDetail())
+	}
+	if propagation.Semantics {
+		details = append(details, err.semanticsDetail())
+	}
+
+	stWithDetails, withDetailErr := st.WithDetails(details...)
 	// if there was an error appending details just send out the standard status without them
 	if withDetailErr != nil {
 		log.Println("couldn't create status with details " + withDetailErr.Error())
@@ -26,35 +47,95 @@ func (err *Err) GRPCStatus() *status.Status {
 	return stWithDetails
 }
 
-// StatusToError converts a status to a nozzle Err.
-func StatusToError(c context.Context, st *status.Status) error {
-	// convert the status to a internal Err
-	e := New(st.Message(), Code(st.Code()))
-	f := e.currentFrame()
+// StatusToError converts a status to a nozzle Err. The options are applied after
+// the status code and details, like they are by New. The frames and semantics sent
+// by the server are restored if the default client accepts them, see SetAcceptPropagation.
+func StatusToError(c context.Context, st *status.Status, opts ...WrapOption) error {
+	return statusToError(c, st, DefaultClient().getAcceptPropagation(), opts...)
+}
+
+// statusToError converts a status, restoring the propagation that is accepted
+func statusToError(c context.Context, st *status.Status, propagation Propagation, opts ...WrapOption) *Err {
+
 	details := st.Details()
-	f.errDetails = make([]proto.Message, 0, len(details))
+	errDetails := make([]proto.Message, 0, len(details))
+	statusOpts := []WrapOption{Code(st.Code())}
+	var remoteFrames stackframes
+	var semanticsOpts []WrapOption
 	for _, detail := range details {
 		switch t := detail.(type) {
+		case *errdetails.RetryInfo:
+			// the delay is kept on the error, and GRPCStatus adds it back
+			statusOpts = append(statusOpts, RetryAfter(t.GetRetryDelay().AsDuration()))
+		case *errdetails.DebugInfo:
+			// the frames are never left in the details, even when they aren't trusted
+			switch {
+			case !isRemoteFrames(t):
+				errDetails = append(errDetails, t)
+			case propagation.Frames:
+				remoteFrames = append(remoteFrames, decodeRemoteFrames(t)...)
+			}
+		case *errdetails.ErrorInfo:
+			// like the frames, the semantics are never left in the details
+			switch {
+			case !isSemantics(t):
+				errDetails = append(errDetails, t)
+			case propagation.Semantics:
+				semanticsOpts = append(semanticsOpts, semantics(t))
+			}
 		case proto.Message:
-			f.errDetails = append(f.errDetails, t)
+			errDetails = append(errDetails, t)
 		case error:
 			// there was error parsing the proto message log it out
 			log.Println("unable to parse proto message " + t.Error())
 		default:
 			// if we don't know the type add to the error as an unknown detail
-			With("unknown detail", t)(e)
+			statusOpts = append(statusOpts, With("unknown detail", t))
 		}
 	}
 
-	return e
+	// the semantics override those derived from the code and the RetryInfo
+	statusOpts = append(statusOpts, semanticsOpts...)
+	statusOpts = append(statusOpts, func(err *Err) {
+		f := err.currentFrame()
+		f.errDetails = append(f.errDetails, errDetails...)
+		err.remoteFrames = remoteFrames
+	})
+
+	// convert the status to a internal Err
+	return New(st.Message(), append(statusOpts, opts...)...)
+}
+
+// hasRetryInfo returns whether the status or the details already have a RetryInfo
+func hasRetryInfo(st *status.Status, details []proto.Message) bool {
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.RetryInfo); ok {
+			return true
+		}
+	}
+
+	for _, detail := range details {
+		if _, ok := detail.(*errdetails.RetryInfo); ok {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Details returns a slice of google.golang.org/genproto/googleapis/rpc/errdetails set on an Err.
 func (err *Err) Details() []proto.Message {
+	err.resolveFrames()
+
 	var details []proto.Message
 	for _, f := range err.frames {
 		details = append(details, f.errDetails...)
 	}
 
+	// joined errors contribute their details too
+	for _, child := range err.children {
+		details = append(details, child.Details()...)
+	}
+
 	return details
 }
@@ -0,0 +1,162 @@
+package e
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// clientInterceptor holds the configuration shared by the client interceptors
+type clientInterceptor struct {
+	client *Client
+}
+
+// ClientInterceptorOption configures UnaryClientInterceptor and StreamClientInterceptor
+type ClientInterceptorOption func(ci *clientInterceptor)
+
+// ClientInterceptorClient sets the client whose accepted propagation is restored from
+// the statuses, see SetAcceptPropagation, and that the errors are reported with
+func ClientInterceptorClient(cl *Client) ClientInterceptorOption {
+	return func(ci *clientInterceptor) {
+		ci.client = cl
+	}
+}
+
+func newClientInterceptor(opts []ClientInterceptorOption) *clientInterceptor {
+	ci := &clientInterceptor{}
+	for _, opt := range opts {
+		opt(ci)
+	}
+
+	return ci
+}
+
+// UnaryClientInterceptor returns an interceptor that converts the status of every
+// failed call into an *Err with its code and details, the method and peer address
+// as vars, and whether the error came from the server, see IsRemote
+func UnaryClientInterceptor(opts ...ClientInterceptorOption) grpc.UnaryClientInterceptor {
+	ci := newClientInterceptor(opts)
+
+	return func(c context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var p peer.Peer
+		var trailer metadata.MD
+		if rawErr := invoker(c, method, req, reply, cc, append(opts, grpc.Peer(&p), grpc.Trailer(&trailer))...); rawErr != nil {
+			return ci.convert(c, method, &p, trailer, rawErr)
+		}
+
+		return nil
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that converts the status of every
+// failed stream into an *Err with its code and details, the method and peer address
+// as vars, and whether the error came from the server, see IsRemote. The io.EOF
+// returned at the end of a stream is left alone.
+func StreamClientInterceptor(opts ...ClientInterceptorOption) grpc.StreamClientInterceptor {
+	ci := newClientInterceptor(opts)
+
+	return func(c context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var p peer.Peer
+		cs, rawErr := streamer(c, desc, cc, method, append(opts, grpc.Peer(&p))...)
+		if rawErr != nil {
+			// the stream wasn't created, so nothing was received from the server
+			return nil, ci.convert(c, method, &p, nil, rawErr)
+		}
+
+		return &grpcClientStream{ClientStream: cs, ci: ci, c: c, method: method}, nil
+	}
+}
+
+// grpcClientStream converts the errors of an established stream
+type grpcClientStream struct {
+	grpc.ClientStream
+	ci     *clientInterceptor
+	c      context.Context
+	method string
+}
+
+// SendMsg fulfills the grpc.ClientStream interface
+func (cs *grpcClientStream) SendMsg(m interface{}) error {
+	return cs.convert(cs.ClientStream.SendMsg(m))
+}
+
+// RecvMsg fulfills the grpc.ClientStream interface
+func (cs *grpcClientStream) RecvMsg(m interface{}) error {
+	return cs.convert(cs.ClientStream.RecvMsg(m))
+}
+
+func (cs *grpcClientStream) convert(rawErr error) error {
+	if rawErr == nil || rawErr == io.EOF {
+		return rawErr
+	}
+
+	p, _ := peer.FromContext(cs.ClientStream.Context())
+	return cs.ci.convert(cs.c, cs.method, p, cs.ClientStream.Trailer(), rawErr)
+}
+
+// convert converts the error of a call. It's remote if the server sent the
+// RemoteTrailer with it, and only then is propagation accepted from its status.
+func (ci *clientInterceptor) convert(c context.Context, method string, p *peer.Peer, trailer metadata.MD, rawErr error) error {
+	// the error was already converted by another interceptor
+	if _, ok := asErr(rawErr); ok {
+		return rawErr
+	}
+
+	var addr string
+	if p != nil && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+
+	opts := []WrapOption{With("grpcMethod", method)}
+	if addr != "" {
+		opts = append(opts, With("grpcPeer", addr))
+	}
+
+	cl := ci.client
+	if cl == nil {
+		cl = DefaultClient()
+	}
+
+	// errors of the transport, e.g. when the connection is reset, have no trailer
+	remote := len(trailer.Get(RemoteTrailer)) > 0
+
+	var err *Err
+	if st, ok := status.FromError(rawErr); ok {
+		var accept Propagation
+		if remote {
+			accept = cl.getAcceptPropagation()
+		}
+		err = statusToError(c, st, accept, opts...)
+	} else {
+		err = Wrap(rawErr, opts...)
+	}
+	err.isRemote = remote
+	if ci.client != nil {
+		err.setClient(ci.client)
+	}
+
+	return err
+}
+
+// IsRemote returns whether the error was returned by the server of a gRPC call, as
+// opposed to the client's transport or context
+func (err *Err) IsRemote() bool {
+	return err.isRemote
+}
+
+// IsRemote returns whether the error was returned by the server of a gRPC call,
+// finding the Err anywhere in the chain. Only errors converted by UnaryClientInterceptor
+// or StreamClientInterceptor can be remote, and only if the server sent the
+// RemoteTrailer, which the server interceptors do.
+func IsRemote(err error) bool {
+	typedErr, ok := asErr(err)
+	if !ok {
+		return false
+	}
+
+	return typedErr.isRemote
+}
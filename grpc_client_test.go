@@ -0,0 +1,149 @@
+package e
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type failingHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (failingHealthServer) Check(c context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return nil, New("unknown service", Code(codes.NotFound), FieldViolation("service", "doesn't exist"))
+}
+
+func (failingHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+
+	return New("shutting down", Code(codes.Unavailable))
+}
+
+func newTestHealthClient(t *testing.T) (healthpb.HealthClient, *bufconn.Listener) {
+	lis := bufconn.Listen(1 << 20)
+	noReport := ServerReportPolicy(func(*Err) bool { return false })
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(noReport)),
+		grpc.StreamInterceptor(StreamServerInterceptor(noReport)),
+	)
+	healthpb.RegisterHealthServer(srv, failingHealthServer{})
+	go srv.Serve(lis) //nolint:errcheck
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(c context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(c)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn), lis
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	client, lis := newTestHealthClient(t)
+	c := context.Background()
+
+	_, rawErr := client.Check(c, &healthpb.HealthCheckRequest{Service: "missing"})
+	err, ok := rawErr.(*Err)
+	require.True(t, ok)
+
+	assert.Equal(t, codes.NotFound, err.Code())
+	assert.True(t, IsRemote(rawErr))
+	assert.Equal(t, "unknown service", err.rootErr.Error())
+	if details := err.Details(); assert.Len(t, details, 1) {
+		assert.IsType(t, &errdetails.BadRequest{}, details[0])
+	}
+
+	err.resolveFrames()
+	// the stack starts at the generated client
+	assert.Equal(t, "(*healthClient).Check", err.frames[0].fn)
+	assert.Equal(t, "TestUnaryClientInterceptor", err.topAppFrame.fn)
+	assert.Equal(t, "/grpc.health.v1.Health/Check", err.frames[0].vars["grpcMethod"])
+	assert.Equal(t, "bufconn", err.frames[0].vars["grpcPeer"])
+
+	// errors from the local transport aren't remote
+	lis.Close()
+	canceled, cancel := context.WithCancel(c)
+	cancel()
+	_, rawErr = client.Check(canceled, &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.Canceled, CodeFromError(rawErr))
+	assert.False(t, IsRemote(rawErr))
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	client, _ := newTestHealthClient(t)
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, CodeFromError(err))
+	assert.True(t, IsRemote(err))
+}
+
+// hangingHealthServer blocks every check until the call is canceled
+type hangingHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	started chan struct{}
+}
+
+func (hs hangingHealthServer) Check(c context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	close(hs.started)
+	<-c.Done()
+	return nil, New("too late", Code(codes.NotFound))
+}
+
+func TestClientInterceptorServerKilled(t *testing.T) {
+	hs := hangingHealthServer{started: make(chan struct{})}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(ServerReportPolicy(func(*Err) bool { return false }))))
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis) //nolint:errcheck
+
+	internal := NewClient(WithAcceptPropagation(Propagation{Frames: true, Semantics: true}))
+	conn, dialErr := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(c context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(c)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(ClientInterceptorClient(internal))),
+	)
+	require.NoError(t, dialErr)
+	t.Cleanup(func() { conn.Close() })
+
+	// the connection is torn down while the handler is running
+	go func() {
+		<-hs.started
+		srv.Stop()
+	}()
+
+	_, rawErr := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	err, ok := rawErr.(*Err)
+	require.True(t, ok)
+	assert.Equal(t, codes.Unavailable, err.Code())
+	assert.False(t, IsRemote(rawErr))
+
+	// the call reached the server, so its address is known
+	err.resolveFrames()
+	assert.Equal(t, "bufconn", err.frames[0].vars["grpcPeer"])
+}
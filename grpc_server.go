@@ -0,0 +1,162 @@
+package e
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RemoteTrailer is the trailer the server interceptors send with every error, which
+// the client interceptors use to tell errors returned by the server from those of
+// the transport, see IsRemote. Servers that don't use the interceptors can send it
+// with any value.
+const RemoteTrailer = "x-nozzle-e-remote"
+
+// serverInterceptor holds the configuration shared by the server interceptors
+type serverInterceptor struct {
+	shouldReport func(err *Err) bool
+	reportOpts   []ReportOption
+	propagation  func(c context.Context, method string) Propagation
+}
+
+// ServerInterceptorOption configures UnaryServerInterceptor and StreamServerInterceptor
+type ServerInterceptorOption func(si *serverInterceptor)
+
+// ServerReportPolicy replaces DefaultServerReportPolicy, deciding which of the errors
+// returned by handlers are reported
+func ServerReportPolicy(fn func(err *Err) bool) ServerInterceptorOption {
+	return func(si *serverInterceptor) {
+		si.shouldReport = fn
+	}
+}
+
+// ServerReportOptions sets the options errors are reported with, e.g. ReportWithClient
+func ServerReportOptions(opts ...ReportOption) ServerInterceptorOption {
+	return func(si *serverInterceptor) {
+		si.reportOpts = opts
+	}
+}
+
+// ServerPropagation decides what is propagated to the caller of each call, e.g. frames
+// and semantics for internal services, identified by their peer or credentials, and
+// nothing for external callers. Nothing is propagated by default.
+func ServerPropagation(fn func(c context.Context, method string) Propagation) ServerInterceptorOption {
+	return func(si *serverInterceptor) {
+		si.propagation = fn
+	}
+}
+
+// DefaultServerReportPolicy reports critical errors, and otherwise every error that
+// isn't the caller's fault, i.e. whose code isn't Canceled, InvalidArgument, NotFound,
+// AlreadyExists, PermissionDenied, FailedPrecondition, OutOfRange or Unauthenticated
+func DefaultServerReportPolicy(err *Err) bool {
+	if err.Level == LevelCritical {
+		return true
+	}
+
+	switch err.code {
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.FailedPrecondition, codes.OutOfRange, codes.Unauthenticated:
+		return false
+	default:
+		return true
+	}
+}
+
+func newServerInterceptor(opts []ServerInterceptorOption) *serverInterceptor {
+	si := &serverInterceptor{
+		shouldReport: DefaultServerReportPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(si)
+	}
+
+	return si
+}
+
+// UnaryServerInterceptor returns an interceptor that recovers panics in handlers,
+// wraps and reports the errors they return according to the report policy, and
+// returns them to the client as statuses with their details
+func UnaryServerInterceptor(opts ...ServerInterceptorOption) grpc.UnaryServerInterceptor {
+	si := newServerInterceptor(opts)
+
+	return func(c context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, rawErr error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				// skip this deferred function, so the stack starts at the panic
+				resp, rawErr = nil, si.handleErr(c, info.FullMethod, panicToErr(c, rec, 1))
+			}
+		}()
+
+		resp, rawErr = handler(c, req)
+		if rawErr != nil {
+			return nil, si.handleErr(c, info.FullMethod, rawErr)
+		}
+
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that recovers panics in handlers,
+// wraps and reports the errors they return according to the report policy, and
+// returns them to the client as statuses with their details
+func StreamServerInterceptor(opts ...ServerInterceptorOption) grpc.StreamServerInterceptor {
+	si := newServerInterceptor(opts)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (rawErr error) {
+		c := ss.Context()
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				// skip this deferred function, so the stack starts at the panic
+				rawErr = si.handleErr(c, info.FullMethod, panicToErr(c, rec, 1))
+			}
+		}()
+
+		if rawErr = handler(srv, ss); rawErr != nil {
+			return si.handleErr(c, info.FullMethod, rawErr)
+		}
+
+		return nil
+	}
+}
+
+// handleErr wraps and reports the error returned by a handler, returning its status
+func (si *serverInterceptor) handleErr(c context.Context, method string, rawErr error) error {
+	// errors that are already wrapped get the options directly, since wrapping them here
+	// would add this function as an unknown frame, as it isn't in their stack
+	err, ok := asErr(rawErr)
+	if ok {
+		err.addIntermediateMsg(intermediateMsgs(rawErr, err))
+	} else {
+		var opts []WrapOption
+		// statuses created by the handler keep their code
+		if st, ok := status.FromError(rawErr); ok {
+			opts = append(opts, Code(st.Code()))
+		}
+		err = Wrap(rawErr, opts...)
+	}
+
+	Tag("grpcMethod", method)(err)
+	err.fromHandler = true
+
+	// errors the handler already reported aren't reported again
+	if si.shouldReport(err) && err.markReported() {
+		err.Report(c, append([]ReportOption{ReportIsHandler()}, si.reportOpts...)...)
+	}
+
+	// the trailer can only fail to be set if the stream is already done, in which
+	// case the client won't see the status either
+	_ = grpc.SetTrailer(c, metadata.Pairs(RemoteTrailer, "1"))
+
+	var propagation Propagation
+	if si.propagation != nil {
+		propagation = si.propagation(c, method)
+	}
+
+	return err.grpcStatus(propagation).Err()
+}
@@ -0,0 +1,137 @@
+package e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testServerStream struct {
+	grpc.ServerStream
+	c context.Context
+}
+
+func (ss *testServerStream) Context() context.Context {
+	return ss.c
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tr := &testReporter{}
+	// a single worker keeps the reports in order
+	cl := NewClient(WithReporter("grpc", tr), WithReportQueue(QueueConfig{Workers: 1}))
+	interceptor := UnaryServerInterceptor(ServerReportOptions(ReportWithClient(cl)))
+
+	c := context.Background()
+	info := &grpc.UnaryServerInfo{FullMethod: "/acme.Svc/Get"}
+	call := func(handler grpc.UnaryHandler) (interface{}, error) {
+		return interceptor(c, "req", info, handler)
+	}
+
+	resp, err := call(func(c context.Context, req interface{}) (interface{}, error) {
+		return "resp", nil
+	})
+	assert.Equal(t, "resp", resp)
+	assert.NoError(t, err)
+
+	// plain errors are wrapped and reported
+	_, err = call(func(c context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("some error")
+	})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+
+	// the caller's fault isn't reported, but the details are returned
+	_, err = call(func(c context.Context, req interface{}) (interface{}, error) {
+		return nil, New("invalid", Code(codes.InvalidArgument), FieldViolation("id", "is required"))
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Len(t, status.Convert(err).Details(), 1)
+
+	// statuses keep their code
+	_, err = call(func(c context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// panics are recovered as critical errors
+	_, err = call(func(c context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "boom", status.Convert(err).Message())
+
+	drain(cl)
+	if assert.Len(t, tr.reported, 3) {
+		for _, reported := range tr.reported {
+			assert.True(t, reported.fromHandler)
+			assert.Equal(t, "/acme.Svc/Get", reported.tags["grpcMethod"])
+		}
+		assert.Equal(t, codes.Unavailable, tr.reported[1].code)
+		assert.True(t, tr.reported[2].isPanic)
+		assert.Equal(t, LevelCritical, tr.reported[2].Level)
+		assert.Equal(t, "e.TestUnaryServerInterceptor.func6", tr.reported[2].topAppFrame.pkg+"."+tr.reported[2].topAppFrame.fn)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(WithReporter("grpc", tr))
+	interceptor := StreamServerInterceptor(
+		ServerReportOptions(ReportWithClient(cl)),
+		ServerReportPolicy(func(err *Err) bool { return true }),
+	)
+
+	ss := &testServerStream{c: context.Background()}
+	info := &grpc.StreamServerInfo{FullMethod: "/acme.Svc/List"}
+
+	err := interceptor(nil, ss, info, func(srv interface{}, stream grpc.ServerStream) error {
+		return New("not found", Code(codes.NotFound))
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	err = interceptor(nil, ss, info, func(srv interface{}, stream grpc.ServerStream) error {
+		panic(errors.New("boom"))
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// errors already reported by the handler aren't reported again
+	err = interceptor(nil, ss, info, func(srv interface{}, stream grpc.ServerStream) error {
+		err := New("reported")
+		err.Report(stream.Context(), ReportWithClient(cl))
+		return err
+	})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+
+	drain(cl)
+	assert.Len(t, tr.reported, 3)
+}
+
+func TestServerInterceptorWrappedErr(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(WithReporter("grpc", tr), WithReportQueue(QueueConfig{Workers: 1}))
+	interceptor := UnaryServerInterceptor(ServerReportOptions(ReportWithClient(cl)))
+	info := &grpc.UnaryServerInfo{FullMethod: "/acme.Svc/Get"}
+
+	// errors returned by the handler aren't wrapped again, which would add an unknown frame
+	for _, rawErr := range []error{New("some error"), fmt.Errorf("loading user: %w", New("some error"))} {
+		_, err := interceptor(context.Background(), "req", info, func(c context.Context, req interface{}) (interface{}, error) {
+			return nil, rawErr
+		})
+		assert.Equal(t, codes.Unknown, status.Code(err))
+	}
+
+	drain(cl)
+	if assert.Len(t, tr.reported, 2) {
+		for _, reported := range tr.reported {
+			assert.Empty(t, reported.unknownFrames)
+			assert.Equal(t, "/acme.Svc/Get", reported.tags["grpcMethod"])
+			assert.NotContains(t, reported.Error(), "UNKNOWN STACK FRAMES")
+		}
+		assert.Equal(t, "loading user", tr.reported[1].frameMsgs())
+	}
+}
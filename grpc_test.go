@@ -0,0 +1,74 @@
+package e
+
+import (
+	"context"
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type retryAfterErr struct{}
+
+func (retryAfterErr) Error() string                { return "rate limited" }
+func (retryAfterErr) ErrRetryAfter() time.Duration { return time.Minute }
+
+func TestRetryAfter(t *testing.T) {
+	err := New("overloaded", Code(codes.ResourceExhausted), RetryAfter(1500*time.Millisecond))
+	assert.True(t, err.IsRetriable())
+
+	// NotRetriable wins whatever the order
+	for _, notRetriable := range []*Err{
+		New("overloaded", NotRetriable(), RetryAfter(time.Second)),
+		New("overloaded", RetryAfter(time.Second), NotRetriable()),
+		Wrap(New("overloaded", NotRetriable()), RetryAfter(time.Second)),
+	} {
+		assert.False(t, notRetriable.IsRetriable())
+		assert.True(t, errors.Is(notRetriable, ErrKindNotRetriable))
+		d, ok := notRetriable.RetryAfter()
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, d)
+	}
+
+	d, ok := RetryAfterFromError(fmt.Errorf("calling: %w", err))
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, d)
+
+	_, ok = RetryAfterFromError(New("no delay"))
+	assert.False(t, ok)
+
+	// the delay is sent as RetryInfo and read back on the other side
+	st := err.GRPCStatus()
+	require.Len(t, st.Details(), 1)
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, retryInfo.GetRetryDelay().AsDuration())
+
+	got := StatusToError(context.Background(), st)
+	d, ok = RetryAfterFromError(got)
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, d)
+	assert.Len(t, got.(*Err).GRPCStatus().Details(), 1)
+
+	// RetryInfo added as a detail isn't duplicated
+	st = mustStatusWithDetails(t, codes.Unavailable, &errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)})
+	assert.Len(t, Wrap(st.Err(), RetryAfter(time.Second)).GRPCStatus().Details(), 1)
+
+	// the interface sets it too
+	d, _ = Wrap(retryAfterErr{}, With("err", retryAfterErr{})).RetryAfter()
+	assert.Equal(t, time.Minute, d)
+
+	b, marshalErr := json.Marshal(err)
+	require.NoError(t, marshalErr)
+	unmarshaled := &Err{}
+	require.NoError(t, json.Unmarshal(b, unmarshaled))
+	d, _ = unmarshaled.RetryAfter()
+	assert.Equal(t, 1500*time.Millisecond, d)
+}
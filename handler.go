@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"log"
-	"runtime/debug"
 
 	"google.golang.org/grpc/codes"
 )
@@ -58,19 +57,6 @@ func Recover(c context.Context, opts ...RecoverOption) {
 		return
 	}
 
-	var rawErr error
-	// find out exactly what the error was and set err
-	switch x := rec.(type) {
-	case string:
-		rawErr = errors.New(x)
-	case []byte:
-		rawErr = errors.New(string(x))
-	case error:
-		rawErr = x
-	default:
-		rawErr = errors.New("unknown panic")
-	}
-
 	rd := &recoverData{
 		shouldReport: true,
 		shouldLog:    true,
@@ -80,13 +66,8 @@ func Recover(c context.Context, opts ...RecoverOption) {
 		opt(c, rd)
 	}
 
-	err := newErr(rawErr, debug.Stack())
-	err.Level = LevelCritical
-	err.code = codes.Internal
-	err.fromHandler = false
-	if c.Err() != nil {
-		err = wrap(err, With("c.Err()", c.Err().Error()))
-	}
+	// skip Recover itself, so the stack starts at the panic
+	err := panicToErr(c, rec, 1)
 
 	if rd.shouldReport {
 		err.Report(c)
@@ -110,3 +91,31 @@ func Recover(c context.Context, opts ...RecoverOption) {
 		panic(err)
 	}
 }
+
+// panicToErr converts a recovered value into a critical error, with the stack of
+// the caller of the function that called panicToErr, skipping skip more frames
+func panicToErr(c context.Context, rec interface{}, skip int) *Err {
+	var rawErr error
+	// find out exactly what the error was and set err
+	switch x := rec.(type) {
+	case string:
+		rawErr = errors.New(x)
+	case []byte:
+		rawErr = errors.New(string(x))
+	case error:
+		rawErr = x
+	default:
+		rawErr = errors.New("unknown panic")
+	}
+
+	err := newErr(rawErr, callers(skip+1))
+	err.Level = LevelCritical
+	err.isPanic = true
+	err.code = codes.Internal
+	err.fromHandler = false
+	if c.Err() != nil {
+		err = wrap(err, With("c.Err()", c.Err().Error()))
+	}
+
+	return err
+}
@@ -1,6 +1,10 @@
 package e
 
-import "google.golang.org/grpc/codes"
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
 
 // ErrVars adds all the returned vars to the current stack frame,
 // overriding any existing keys
@@ -33,6 +37,11 @@ type ErrRetriable interface {
 	ErrRetriable() bool
 }
 
+// ErrRetryAfter sets how long to wait before retrying, see RetryAfter
+type ErrRetryAfter interface {
+	ErrRetryAfter() time.Duration
+}
+
 // ErrReportable sets whether the error should be reported
 type ErrReportable interface {
 	ErrShouldReport() bool
@@ -72,6 +81,12 @@ func getWrapOptionsFromInterfaces(v interface{}) []WrapOption {
 		}
 	}
 
+	if interfacer, ok := v.(ErrRetryAfter); ok {
+		if d := interfacer.ErrRetryAfter(); d > 0 {
+			opts = append(opts, RetryAfter(d))
+		}
+	}
+
 	if interfacer, ok := v.(ErrReportable); ok {
 		if !interfacer.ErrShouldReport() {
 			opts = append(opts, NoReport())
@@ -0,0 +1,161 @@
+package e
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+// multiUnwrapper is implemented by errors with several causes, e.g. from errors.Join
+type multiUnwrapper interface {
+	Unwrap() []error
+}
+
+// joinError is the root error of an Err returned by Join. It unwraps to the
+// children, so errors.Is and errors.As match any of them.
+type joinError struct {
+	errs []error
+}
+
+// Error joins the short messages of the children, one per line
+func (je *joinError) Error() string {
+	msgs := make([]string, 0, len(je.errs))
+	for _, err := range je.errs {
+		msgs = append(msgs, fmt.Sprint(err))
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap fulfills the multi error Unwrap interface
+func (je *joinError) Unwrap() []error {
+	return je.errs
+}
+
+// Join returns an error that keeps every non-nil error as a child with its own
+// frames, or nil if there aren't any. Errors that aren't already wrapped are wrapped
+// at the caller of Join. The joined error is critical if any child is, retriable
+// only if all children are, and has a code if all children share it.
+func Join(errs ...error) error {
+	return join(errs, callers(1))
+}
+
+func join(errs []error, pcs []uintptr) error {
+	nonNil := make([]error, 0, len(errs))
+	for _, err := range errs {
+		if err != nil {
+			nonNil = append(nonNil, err)
+		}
+	}
+
+	if len(nonNil) == 0 {
+		return nil
+	}
+
+	children := childErrs(nonNil, pcs)
+	err := newErr(newJoinError(children), pcs)
+	err.setChildren(children)
+
+	return err
+}
+
+// newJoinError returns the root error of an Err with the children
+func newJoinError(children []*Err) *joinError {
+	errs := make([]error, 0, len(children))
+	for _, child := range children {
+		errs = append(errs, child)
+	}
+
+	return &joinError{errs: errs}
+}
+
+// childErrs finds the Err of each error, initializing one with the given stack
+// for errors that aren't wrapped
+func childErrs(errs []error, pcs []uintptr) []*Err {
+	children := make([]*Err, 0, len(errs))
+	for _, rawErr := range errs {
+		if rawErr == nil {
+			continue
+		}
+
+		child, ok := asErr(rawErr)
+		if !ok {
+			child = newErr(rawErr, pcs)
+		}
+		children = append(children, child)
+	}
+
+	return children
+}
+
+// setChildren attaches the children and derives the level, code and retriability from them
+func (err *Err) setChildren(children []*Err) {
+	err.children = children
+	if len(children) == 0 {
+		return
+	}
+
+	err.code = children[0].code
+	err.Level = LevelWarning
+	for _, child := range children {
+		if child.code != err.code {
+			err.code = codes.Unknown
+		}
+
+		// lower levels are more severe
+		if child.Level < err.Level {
+			err.Level = child.Level
+		}
+
+		if !child.isRetriable {
+			err.isRetriable = false
+		}
+	}
+}
+
+// Errors collects errors that happen independently, e.g. in a loop or across
+// goroutines, so that they can be returned together. The zero value is ready to
+// use and it is safe for concurrent use.
+type Errors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// Add wraps err with the options at the caller of Add and collects it. Nil errors are ignored.
+func (es *Errors) Add(err error, opts ...WrapOption) {
+	if err == nil {
+		return
+	}
+
+	// wrap is called directly, so that the frames start at the caller of Add
+	wrapped := wrap(err, opts...)
+
+	es.mu.Lock()
+	es.errs = append(es.errs, wrapped)
+	es.mu.Unlock()
+}
+
+// Len returns the number of collected errors
+func (es *Errors) Len() int {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	return len(es.errs)
+}
+
+// Err returns the collected errors joined as with Join, or nil if none were added
+func (es *Errors) Err() error {
+	es.mu.Lock()
+	errs := append([]error(nil), es.errs...)
+	es.mu.Unlock()
+
+	return join(errs, callers(1))
+}
+
+// Children returns the errors joined into this error with Join, Errors or an error
+// that unwraps to several errors, such as one returned by errors.Join
+func (err *Err) Children() []*Err {
+	return err.children
+}
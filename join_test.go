@@ -0,0 +1,150 @@
+package e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestJoin(t *testing.T) {
+	assert.Nil(t, Join())
+	assert.Nil(t, Join(nil, nil))
+
+	notFound := New("no rows", Code(codes.NotFound), NotRetriable(), Critical())
+	joined := Join(io.EOF, nil, notFound)
+
+	err, ok := joined.(*Err)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	if assert.Len(t, err.Children(), 2) {
+		assert.Equal(t, io.EOF, err.children[0].rootErr)
+		assert.Same(t, notFound, err.children[1])
+	}
+
+	// every child matches through the multi error Unwrap
+	assert.True(t, errors.Is(joined, io.EOF))
+	assert.True(t, errors.Is(joined, ErrNotFound))
+	assert.Equal(t, "EOF\nno rows", fmt.Sprint(joined))
+
+	// the joined error is as severe as its most severe child
+	assert.Equal(t, LevelCritical, err.Level)
+	assert.Equal(t, codes.Unknown, err.code)
+	assert.False(t, err.isRetriable)
+
+	// each child has its own frames
+	out := err.Error()
+	assert.Contains(t, out, "=== JOINED ERROR 1 OF 2 ===\nEOF\n")
+	assert.Contains(t, out, "=== JOINED ERROR 2 OF 2 ===\nno rows\n")
+	assert.Equal(t, 3, strings.Count(out, "e.TestJoin\n"))
+
+	exceptions := err.sentryExceptions()
+	if assert.Len(t, exceptions, 3) {
+		assert.Equal(t, "EOF", exceptions[0].Type)
+		assert.Equal(t, "no rows", exceptions[1].Type)
+		assert.Equal(t, "EOF\nno rows", exceptions[2].Type)
+	}
+}
+
+func TestJoinSharedCode(t *testing.T) {
+	err := Join(New("a", Code(codes.NotFound)), New("b", Code(codes.NotFound), Warning())).(*Err)
+
+	assert.Equal(t, codes.NotFound, err.code)
+	assert.Equal(t, LevelError, err.Level)
+	assert.True(t, err.isRetriable)
+}
+
+func TestJoinDetails(t *testing.T) {
+	badRequest := &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "name"}}}
+	debugInfo := &errdetails.DebugInfo{Detail: "detail"}
+
+	a := StatusToError(context.Background(), mustStatusWithDetails(t, codes.InvalidArgument, badRequest))
+	b := StatusToError(context.Background(), mustStatusWithDetails(t, codes.InvalidArgument, debugInfo))
+
+	st := Join(a, b).(*Err).GRPCStatus()
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Len(t, st.Details(), 2)
+}
+
+func mustStatusWithDetails(t *testing.T, code codes.Code, detail proto.Message) *status.Status {
+	st, err := status.New(code, "invalid").WithDetails(detail)
+	assert.NoError(t, err)
+
+	return st
+}
+
+func TestWrapMultiUnwrap(t *testing.T) {
+	child := New("child", Code(codes.NotFound))
+	stdJoined := errors.Join(io.EOF, child)
+	err := Wrap(stdJoined)
+
+	if assert.Len(t, err.children, 2) {
+		assert.Equal(t, io.EOF, err.children[0].rootErr)
+		assert.Same(t, child, err.children[1])
+	}
+
+	// like Join, the root error is made of the children
+	assert.Equal(t, "EOF\nchild", err.rootErr.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestWrapStdJoin(t *testing.T) {
+	err := Wrap(errors.Join(New("first", Msg("loading")), New("second")))
+
+	// the children are rendered with their short messages, not their full reports
+	assert.Equal(t, "loading: first\nsecond", fmt.Sprintf("%v", err))
+
+	exceptions := err.sentryEvent(context.Background()).Exception
+	if assert.Len(t, exceptions, 3) {
+		assert.Equal(t, "first", exceptions[0].Type)
+		assert.Equal(t, "second", exceptions[1].Type)
+		assert.Equal(t, "loading: first\nsecond", exceptions[2].Type)
+		assert.NotContains(t, exceptions[2].Value, "Error Code")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var es Errors
+	assert.Nil(t, es.Err())
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			es.Add(nil)
+			es.Add(fmt.Errorf("task %d failed", i), With("task", i))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, es.Len())
+
+	err, ok := es.Err().(*Err)
+	if assert.True(t, ok) && assert.Len(t, err.children, 5) {
+		for _, child := range err.children {
+			child.resolveFrames()
+			if assert.NotEmpty(t, child.frames) {
+				assert.Equal(t, "github.com/nozzle/e.TestErrors.func1", child.frames[0].full)
+				assert.Contains(t, child.frames[0].vars, "task")
+			}
+		}
+
+		err.resolveFrames()
+		if assert.NotEmpty(t, err.frames) {
+			assert.Equal(t, "github.com/nozzle/e.TestErrors", err.frames[0].full)
+		}
+	}
+}
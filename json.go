@@ -0,0 +1,347 @@
+package e
+
+import (
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// JSONSchemaVersion is the version of the JSON representation of Err written by
+// MarshalJSON. It is incremented whenever the schema changes in a way that isn't
+// backwards compatible, and UnmarshalJSON rejects versions newer than this.
+//
+// Version 1 has the following schema, where tags, frames, children and the optional
+// frame fields are omitted when empty. Fields may be added within a version, as
+// older readers ignore them.
+//
+//	{
+//	  "version": 1,
+//	  "message": "root error message",
+//	  "code": "NotFound",                    // codes.Code.String()
+//	  "level": "error",                      // critical, error or warning
+//	  "flags": {"retriable": true, "noReport": false, "panic": false, "infra": false},
+//	  "remote": true,                        // returned by a gRPC server, omitted when false
+//	  "retryAfter": "1.5s",                  // time.Duration.String(), only set with RetryAfter
+//	  "tags": {"key": "value"},
+//	  "fingerprint": ["part"],               // only set if overridden with Fingerprint
+//	  "frames": [{                           // top of the stack first
+//	    "msg": "message added with Msg",
+//	    "path": "github.com/acme/app/server",
+//	    "pkg": "server",
+//	    "fn": "(*Server).Get",
+//	    "full": "github.com/acme/app/server.(*Server).Get",
+//	    "file": "server.go",
+//	    "absPath": "/src/app/server/server.go",
+//	    "line": 10,
+//	    "class": "app",                      // app, pkg, vendor, stdlib or panic
+//	    "vars": {"key": "any json value"},   // unmarshalable values are rendered with %#v
+//	    "details": [{"@type": "type.googleapis.com/google.rpc.BadRequest", ...}] // protojson
+//	  }],
+//	  "unknownFrames": [...],                // same schema as frames
+//	  "remoteFrames": [...],                 // same schema, decoded from a gRPC status
+//	  "children": [{"version": 1, ...}]      // errors joined with Join, same schema as this one
+//	}
+//
+// Unmarshaling is lossy where JSON is: vars are decoded into interface{} values,
+// and the root error becomes a plain errors.New error.
+const JSONSchemaVersion = 1
+
+type jsonErr struct {
+	Version       int               `json:"version"`
+	Message       string            `json:"message"`
+	Code          string            `json:"code"`
+	Level         string            `json:"level"`
+	Flags         jsonFlags         `json:"flags"`
+	Remote        bool              `json:"remote,omitempty"`
+	RetryAfter    string            `json:"retryAfter,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	Fingerprint   []string          `json:"fingerprint,omitempty"`
+	Frames        []*jsonFrame      `json:"frames,omitempty"`
+	UnknownFrames []*jsonFrame      `json:"unknownFrames,omitempty"`
+	RemoteFrames  []*jsonFrame      `json:"remoteFrames,omitempty"`
+	Children      []*jsonErr        `json:"children,omitempty"`
+}
+
+type jsonFlags struct {
+	Retriable bool `json:"retriable"`
+	NoReport  bool `json:"noReport"`
+	Panic     bool `json:"panic"`
+	Infra     bool `json:"infra"`
+}
+
+type jsonFrame struct {
+	Msg     string                     `json:"msg,omitempty"`
+	Path    string                     `json:"path,omitempty"`
+	Pkg     string                     `json:"pkg,omitempty"`
+	Fn      string                     `json:"fn,omitempty"`
+	Full    string                     `json:"full,omitempty"`
+	File    string                     `json:"file,omitempty"`
+	AbsPath string                     `json:"absPath,omitempty"`
+	Line    int                        `json:"line,omitempty"`
+	Class   string                     `json:"class,omitempty"`
+	Vars    map[string]json.RawMessage `json:"vars,omitempty"`
+	Details []json.RawMessage          `json:"details,omitempty"`
+}
+
+// MarshalJSON fulfills the json.Marshaler interface. See JSONSchemaVersion for the schema.
+// Tags, vars and frame messages are scrubbed, see SetScrubRules.
+func (err *Err) MarshalJSON() ([]byte, error) {
+	return json.Marshal(err.toJSON())
+}
+
+func (err *Err) toJSON() *jsonErr {
+	err.resolveFrames()
+
+	cl := err.cl()
+	je := &jsonErr{
+		Version: JSONSchemaVersion,
+		Message: err.rootErr.Error(),
+		Code:    err.code.String(),
+		Level:   err.Level.String(),
+		Flags: jsonFlags{
+			Retriable: err.isRetriable,
+			NoReport:  err.noReport,
+			Panic:     err.isPanic,
+			Infra:     err.isInfra,
+		},
+		Remote:        err.isRemote,
+		RetryAfter:    durationString(err.retryAfter),
+		Tags:          cl.scrub.tags(err.tags),
+		Fingerprint:   err.fingerprint,
+		Frames:        make([]*jsonFrame, 0, len(err.frames)),
+		UnknownFrames: make([]*jsonFrame, 0, len(err.unknownFrames)),
+	}
+
+	for _, f := range err.frames {
+		je.Frames = append(je.Frames, f.toJSON(cl.scrub))
+	}
+
+	for _, f := range err.unknownFrames {
+		je.UnknownFrames = append(je.UnknownFrames, f.toJSON(cl.scrub))
+	}
+
+	for _, f := range err.remoteFrames {
+		je.RemoteFrames = append(je.RemoteFrames, f.toJSON(cl.scrub))
+	}
+
+	for _, child := range err.children {
+		je.Children = append(je.Children, child.toJSON())
+	}
+
+	return je
+}
+
+// UnmarshalJSON fulfills the json.Unmarshaler interface. See JSONSchemaVersion for the schema.
+func (err *Err) UnmarshalJSON(b []byte) error {
+	je := &jsonErr{}
+	if unmarshalErr := json.Unmarshal(b, je); unmarshalErr != nil {
+		return unmarshalErr
+	}
+
+	return err.fromJSON(je)
+}
+
+func (err *Err) fromJSON(je *jsonErr) error {
+	if je.Version < 1 || je.Version > JSONSchemaVersion {
+		return errors.New("unsupported Err JSON schema version " + strconv.Itoa(je.Version))
+	}
+
+	code, ok := codeFromString(je.Code)
+	if !ok {
+		return errors.New("invalid error code " + strconv.Quote(je.Code))
+	}
+
+	level, ok := levelFromString(je.Level)
+	if !ok {
+		return errors.New("invalid error level " + strconv.Quote(je.Level))
+	}
+
+	var retryAfter time.Duration
+	if je.RetryAfter != "" {
+		var parseErr error
+		if retryAfter, parseErr = time.ParseDuration(je.RetryAfter); parseErr != nil {
+			return errors.New("invalid retry after " + strconv.Quote(je.RetryAfter))
+		}
+	}
+
+	*err = Err{
+		rootErr:     errors.New(je.Message),
+		code:        code,
+		Level:       level,
+		tags:        je.Tags,
+		fingerprint: je.Fingerprint,
+		isRetriable: je.Flags.Retriable,
+		retryAfter:  retryAfter,
+		noReport:    je.Flags.NoReport,
+		isPanic:     je.Flags.Panic,
+		isInfra:     je.Flags.Infra,
+		isRemote:    je.Remote,
+
+		// the frames come from the JSON, there's no stack to resolve
+		framesResolved: true,
+	}
+
+	for _, jf := range je.Frames {
+		err.frames = append(err.frames, jf.toFrame())
+	}
+
+	for _, jf := range je.UnknownFrames {
+		err.unknownFrames = append(err.unknownFrames, jf.toFrame())
+	}
+
+	for _, jf := range je.RemoteFrames {
+		err.remoteFrames = append(err.remoteFrames, jf.toFrame())
+	}
+
+	if len(je.Children) > 0 {
+		err.children = make([]*Err, 0, len(je.Children))
+		for _, jc := range je.Children {
+			child := &Err{}
+			if childErr := child.fromJSON(jc); childErr != nil {
+				return childErr
+			}
+			err.children = append(err.children, child)
+		}
+
+		// like Join, the root error unwraps to the children
+		err.rootErr = newJoinError(err.children)
+	}
+
+	if len(err.frames) > 0 {
+		err.setTopAppFrame()
+	}
+
+	return nil
+}
+
+func (f *frame) toJSON(s *scrubber) *jsonFrame {
+	jf := &jsonFrame{
+		Msg:     s.str(f.msg),
+		Path:    f.path,
+		Pkg:     f.pkg,
+		Fn:      f.fn,
+		Full:    f.full,
+		File:    f.file,
+		AbsPath: f.absPath,
+		Line:    f.line,
+		Class:   f.class.String(),
+	}
+
+	if len(f.vars) > 0 {
+		jf.Vars = make(map[string]json.RawMessage, len(f.vars))
+		for k, v := range s.vars(f.vars) {
+			b, marshalErr := json.Marshal(v)
+			if marshalErr != nil {
+				// fall back to the same representation Error() uses
+				b, _ = json.Marshal(fmt.Sprintf("%#v", v))
+			}
+			jf.Vars[k] = b
+		}
+	}
+
+	for _, detail := range f.errDetails {
+		a, anyErr := anypb.New(proto.MessageV2(detail))
+		if anyErr != nil {
+			log.Println("unable to marshal error detail " + anyErr.Error())
+			continue
+		}
+
+		b, marshalErr := protojson.Marshal(a)
+		if marshalErr != nil {
+			log.Println("unable to marshal error detail " + marshalErr.Error())
+			continue
+		}
+		jf.Details = append(jf.Details, b)
+	}
+
+	return jf
+}
+
+func (jf *jsonFrame) toFrame() *frame {
+	f := &frame{
+		msg:     jf.Msg,
+		path:    jf.Path,
+		pkg:     jf.Pkg,
+		fn:      jf.Fn,
+		full:    jf.Full,
+		file:    jf.File,
+		absPath: jf.AbsPath,
+		line:    jf.Line,
+		class:   classFromString(jf.Class),
+	}
+
+	if len(jf.Vars) > 0 {
+		f.vars = make(map[string]interface{}, len(jf.Vars))
+		for k, raw := range jf.Vars {
+			var v interface{}
+			if unmarshalErr := json.Unmarshal(raw, &v); unmarshalErr != nil {
+				v = string(raw)
+			}
+			f.vars[k] = v
+		}
+	}
+
+	for _, raw := range jf.Details {
+		a := &anypb.Any{}
+		if unmarshalErr := protojson.Unmarshal(raw, a); unmarshalErr != nil {
+			// the detail type isn't linked into this binary, so it can't be decoded
+			log.Println("unable to unmarshal error detail " + unmarshalErr.Error())
+			continue
+		}
+
+		detail, unmarshalErr := a.UnmarshalNew()
+		if unmarshalErr != nil {
+			log.Println("unable to unmarshal error detail " + unmarshalErr.Error())
+			continue
+		}
+		f.errDetails = append(f.errDetails, proto.MessageV1(detail))
+	}
+
+	return f
+}
+
+func durationString(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+
+	return d.String()
+}
+
+func levelFromString(s string) (Level, bool) {
+	for _, l := range []Level{LevelCritical, LevelError, LevelWarning} {
+		if l.String() == s {
+			return l, true
+		}
+	}
+
+	return 0, false
+}
+
+func codeFromString(s string) (codes.Code, bool) {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if c.String() == s {
+			return c, true
+		}
+	}
+
+	return codes.Unknown, false
+}
+
+func classFromString(s string) class {
+	for _, c := range []class{classApp, classPkg, classVendor, classStdLib, classPanic} {
+		if c.String() == s {
+			return c
+		}
+	}
+
+	return 0
+}
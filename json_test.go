@@ -0,0 +1,123 @@
+package e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrJSON(t *testing.T) {
+	want := New("some error",
+		Code(codes.NotFound),
+		Warning(),
+		Infra(),
+		Tag("tagKey", "tagVal"),
+		Msg("some message"),
+		With("str", "val"),
+		With("json", []byte(`{"a":1}`)),
+		With("unmarshalable", func() {}),
+		FieldViolation("field", "is required"),
+	)
+
+	b, err := json.Marshal(want)
+	require.NoError(t, err)
+
+	got := &Err{}
+	require.NoError(t, json.Unmarshal(b, got))
+
+	assert.Equal(t, want.rootErr.Error(), got.rootErr.Error())
+	assert.Equal(t, codes.NotFound, got.code)
+	assert.Equal(t, LevelWarning, got.Level)
+	assert.True(t, got.isInfra)
+	assert.True(t, got.isRetriable)
+	assert.False(t, got.noReport)
+	assert.Equal(t, map[string]string{"tagKey": "tagVal"}, got.tags)
+	require.Len(t, got.frames, len(want.frames))
+
+	for i := range want.frames {
+		wf, gf := want.frames[i], got.frames[i]
+		assert.Equal(t, wf.msg, gf.msg)
+		assert.Equal(t, wf.full, gf.full)
+		assert.Equal(t, wf.absPath, gf.absPath)
+		assert.Equal(t, wf.line, gf.line)
+		assert.Equal(t, wf.class, gf.class)
+		require.Len(t, gf.errDetails, len(wf.errDetails))
+		for j := range wf.errDetails {
+			assert.True(t, proto.Equal(wf.errDetails[j], gf.errDetails[j]))
+		}
+	}
+
+	vars := got.frames[0].vars
+	assert.Equal(t, "val", vars["str"])
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, vars["json"])
+	assert.Contains(t, vars["unmarshalable"], "(func())")
+	assert.Equal(t, got.frames[0], got.topAppFrame)
+
+	// re-marshaling the unmarshaled error is stable
+	b2, err := json.Marshal(got)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(b), string(b2))
+}
+
+func TestErrJSONChildren(t *testing.T) {
+	remoteErr := New("unavailable", Code(codes.Unavailable))
+	remoteErr.isRemote = true
+	want := Join(New("not found", Code(codes.NotFound), Msg("loading user")), remoteErr).(*Err)
+
+	b, err := json.Marshal(want)
+	require.NoError(t, err)
+
+	got := &Err{}
+	require.NoError(t, json.Unmarshal(b, got))
+
+	assert.Equal(t, want.rootErr.Error(), got.rootErr.Error())
+	require.Len(t, got.children, 2)
+	assert.Equal(t, "loading user: not found", got.children[0].shortString())
+	assert.Equal(t, codes.NotFound, got.children[0].code)
+	assert.False(t, got.children[0].isRemote)
+	assert.Equal(t, codes.Unavailable, got.children[1].code)
+	assert.True(t, got.children[1].isRemote)
+	assert.True(t, IsRemote(got.children[1]))
+
+	// the children are unwrapped like those of a joined error
+	assert.True(t, errors.Is(got, got.children[1]))
+
+	b2, err := json.Marshal(got)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(b), string(b2))
+}
+
+func TestErrJSONNoFrames(t *testing.T) {
+	got := &Err{}
+	require.NoError(t, json.Unmarshal([]byte(`{"version":1,"message":"x","code":"Unknown","level":"error","flags":{}}`), got))
+	assert.Nil(t, got.topAppFrame)
+
+	// every reporter can render an error without frames
+	tr := &testReporter{}
+	got.Report(context.Background(), ReportWithClient(NewClient(WithReporter("test", tr))), Wait())
+	assert.Len(t, tr.reported, 1)
+	assert.NotPanics(t, func() {
+		event := got.sentryEvent(context.Background())
+		assert.NotContains(t, event.Tags, "fn")
+		assert.NotEmpty(t, got.Fingerprint())
+		assert.Contains(t, got.Error(), "x")
+		got.LogValue()
+	})
+}
+
+func TestErrJSONInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`{"version":2,"message":"some error","code":"NotFound","level":"error"}`,
+		`{"version":1,"message":"some error","code":"NOT_FOUND","level":"error"}`,
+		`{"version":1,"message":"some error","code":"NotFound","level":"fatal"}`,
+		`{"version":1,"message":"some error","code":"NotFound","level":"error","children":[{"version":2}]}`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(raw), &Err{}), raw)
+	}
+}
@@ -0,0 +1,114 @@
+package e
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Labels identify who and what was affected by an error
+type Labels struct {
+	UserID    string
+	TenantID  string
+	TraceID   string
+	SpanID    string
+	RequestID string
+
+	// Request is the active HTTP request, which is attached to reports
+	Request *http.Request
+
+	// Tags are added to reports alongside the tags set with the Tag WrapOption, which
+	// win when both set the same key
+	Tags map[string]string
+}
+
+// ContextLabeler extracts labels from the context passed to Report, so that every
+// report says who was affected without each call site adding Tag options
+type ContextLabeler interface {
+	ContextLabels(c context.Context) Labels
+}
+
+// ContextLabelerFunc lets an ordinary function be used as a ContextLabeler
+type ContextLabelerFunc func(c context.Context) Labels
+
+// ContextLabels fulfills the ContextLabeler interface
+func (fn ContextLabelerFunc) ContextLabels(c context.Context) Labels {
+	return fn(c)
+}
+
+// AddContextLabeler registers a labeler on the default client that runs on every
+// report. When multiple labelers set the same label, the one added last wins.
+func AddContextLabeler(l ContextLabeler) {
+	DefaultClient().AddContextLabeler(l)
+}
+
+// labelerSet keeps the registered labelers in the order they were added
+type labelerSet struct {
+	mu       sync.RWMutex
+	labelers []ContextLabeler
+}
+
+func (ls *labelerSet) add(l ContextLabeler) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.labelers = append(ls.labelers[:len(ls.labelers):len(ls.labelers)], l)
+}
+
+func (ls *labelerSet) list() []ContextLabeler {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	return ls.labelers
+}
+
+// labels runs all labelers registered on the client and merges their labels
+func (cl *Client) labels(c context.Context) Labels {
+	ls := cl.labelers.list()
+
+	var merged Labels
+	for _, l := range ls {
+		merged.merge(l.ContextLabels(c))
+	}
+
+	return merged
+}
+
+// merge overwrites any labels that are set in other
+func (l *Labels) merge(other Labels) {
+	setIfNotEmpty := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+
+	setIfNotEmpty(&l.UserID, other.UserID)
+	setIfNotEmpty(&l.TenantID, other.TenantID)
+	setIfNotEmpty(&l.TraceID, other.TraceID)
+	setIfNotEmpty(&l.SpanID, other.SpanID)
+	setIfNotEmpty(&l.RequestID, other.RequestID)
+
+	if other.Request != nil {
+		l.Request = other.Request
+	}
+
+	for k, v := range other.Tags {
+		if l.Tags == nil {
+			l.Tags = make(map[string]string, len(other.Tags))
+		}
+		l.Tags[k] = v
+	}
+}
+
+// setTags adds the labels to the report tags
+func (l *Labels) setTags(tags map[string]string) {
+	for k, v := range l.Tags {
+		setTagIfNotEmpty(tags, k, v)
+	}
+
+	setTagIfNotEmpty(tags, "userID", l.UserID)
+	setTagIfNotEmpty(tags, "tenantID", l.TenantID)
+	setTagIfNotEmpty(tags, "traceID", l.TraceID)
+	setTagIfNotEmpty(tags, "spanID", l.SpanID)
+	setTagIfNotEmpty(tags, "requestID", l.RequestID)
+}
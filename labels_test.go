@@ -0,0 +1,46 @@
+package e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type userIDKey struct{}
+
+func TestContextLabeler(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(
+		WithReporter("labels", tr),
+		WithContextLabeler(ContextLabelerFunc(func(c context.Context) Labels {
+			userID, _ := c.Value(userIDKey{}).(string)
+			return Labels{UserID: userID, TraceID: "trace-1", Tags: map[string]string{"region": "us"}}
+		})),
+		WithContextLabeler(ContextLabelerFunc(func(c context.Context) Labels {
+			return Labels{TraceID: "trace-2", TenantID: "tenant"}
+		})),
+	)
+
+	c := context.WithValue(context.Background(), userIDKey{}, "user")
+	New("some error", Tag("region", "eu")).Report(c, ReportWithClient(cl))
+	drain(cl)
+
+	if assert.Len(t, tr.reported, 1) {
+		err := tr.reported[0]
+		assert.Equal(t, Labels{
+			UserID:   "user",
+			TenantID: "tenant",
+			TraceID:  "trace-2",
+			Tags:     map[string]string{"region": "us"},
+		}, err.labels)
+
+		tags := err.sentryTags(c)
+		assert.Equal(t, "user", tags["userID"])
+		assert.Equal(t, "tenant", tags["tenantID"])
+		assert.Equal(t, "trace-2", tags["traceID"])
+		// tags set with the Tag option win over the labels
+		assert.Equal(t, "eu", tags["region"])
+		assert.Equal(t, "user", err.sentryEvent(c).User.ID)
+	}
+}
@@ -0,0 +1,195 @@
+package e
+
+import (
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// LinkSource is the location of a stack frame that a link is built for
+type LinkSource struct {
+	// ImportPath is the package import path, e.g. "github.com/acme/app/server"
+	ImportPath string
+	// File is the base name of the source file, e.g. "server.go"
+	File string
+	// AbsPath is the absolute path of the source file on the machine that built the binary
+	AbsPath string
+	// Line is the line number within the file
+	Line int
+}
+
+// LinkBuilder returns a link to the source of a stack frame, or "" if it can't
+// build one, e.g. because the frame isn't part of the repository
+type LinkBuilder func(src LinkSource) string
+
+// SetLinkBuilder sets the link builder the default client uses for every frame in
+// Error() and Sentry stacktraces. Passing nil disables links.
+func SetLinkBuilder(lb LinkBuilder) {
+	DefaultClient().SetLinkBuilder(lb)
+}
+
+// link returns the link to the source of the frame, or "" if there isn't one
+func (cl *Client) link(f *frame) string {
+	cl.linkBuilderMu.RLock()
+	lb := cl.linkBuilder
+	cl.linkBuilderMu.RUnlock()
+
+	if lb == nil {
+		return ""
+	}
+
+	return lb(LinkSource{
+		ImportPath: f.path,
+		File:       f.file,
+		AbsPath:    f.absPath,
+		Line:       f.line,
+	})
+}
+
+// LinkTemplate builds links for frames whose import path is within repoRoot by
+// filling in the placeholders of tmpl:
+//
+//	{path}     the file path relative to repoRoot, e.g. "server/server.go"
+//	{abspath}  the absolute path of the file, e.g. "/src/app/server/server.go"
+//	{line}     the line number
+//	{revision} the revision passed in
+//
+// If repoRoot is empty, links are built for every frame.
+func LinkTemplate(repoRoot, revision, tmpl string) LinkBuilder {
+	return func(src LinkSource) string {
+		if repoRoot != "" && !hasPathPrefix(src.ImportPath, repoRoot) {
+			return ""
+		}
+
+		relPath := strings.TrimPrefix(strings.TrimPrefix(src.ImportPath, repoRoot), "/")
+		if relPath != "" {
+			relPath += "/"
+		}
+
+		return strings.NewReplacer(
+			"{path}", relPath+src.File,
+			"{abspath}", src.AbsPath,
+			"{line}", strconv.Itoa(src.Line),
+			"{revision}", revision,
+		).Replace(tmpl)
+	}
+}
+
+// GitHubLinks builds links such as https://github.com/acme/app/blob/<revision>/server/server.go#L10,
+// where repoURL is https://github.com/acme/app and repoRoot is the import path of the
+// repository root, e.g. github.com/acme/app
+func GitHubLinks(repoURL, repoRoot, revision string) LinkBuilder {
+	return LinkTemplate(repoRoot, revision, strings.TrimSuffix(repoURL, "/")+"/blob/{revision}/{path}#L{line}")
+}
+
+// GitLabLinks builds links such as https://gitlab.com/acme/app/-/blob/<revision>/server/server.go#L10
+func GitLabLinks(repoURL, repoRoot, revision string) LinkBuilder {
+	return LinkTemplate(repoRoot, revision, strings.TrimSuffix(repoURL, "/")+"/-/blob/{revision}/{path}#L{line}")
+}
+
+// BitbucketLinks builds links such as https://bitbucket.org/acme/app/src/<revision>/server/server.go#lines-10
+func BitbucketLinks(repoURL, repoRoot, revision string) LinkBuilder {
+	return LinkTemplate(repoRoot, revision, strings.TrimSuffix(repoURL, "/")+"/src/{revision}/{path}#lines-{line}")
+}
+
+// GiteaLinks builds links such as https://gitea.example.com/acme/app/src/commit/<revision>/server/server.go#L10
+func GiteaLinks(repoURL, repoRoot, revision string) LinkBuilder {
+	return LinkTemplate(repoRoot, revision, strings.TrimSuffix(repoURL, "/")+"/src/commit/{revision}/{path}#L{line}")
+}
+
+// VSCodeLinks builds links that open every frame in a local VS Code, such as
+// vscode://file/src/app/server/server.go:10
+func VSCodeLinks() LinkBuilder {
+	return func(src LinkSource) string {
+		if src.AbsPath == "" {
+			return ""
+		}
+
+		return "vscode://file/" + strings.TrimPrefix(filepath.ToSlash(src.AbsPath), "/") + ":" + strconv.Itoa(src.Line)
+	}
+}
+
+// BuildRevision returns the VCS revision the binary was built from according to
+// debug.ReadBuildInfo, or "main" if it wasn't stamped, e.g. in tests
+func BuildRevision() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				return setting.Value
+			}
+		}
+	}
+
+	return "main"
+}
+
+// DefaultLinkBuilder returns the link builder used when SetLinkBuilder hasn't been
+// called. If the main module is hosted on GitHub, GitLab or Bitbucket, links point
+// to its repository at BuildRevision, otherwise no links are built. Only frames of
+// the main module get links, and a major version suffix such as /v2 is assumed to
+// be a branch rather than a directory, so modules laid out in major version
+// subdirectories need a builder set with SetLinkBuilder.
+func DefaultLinkBuilder() LinkBuilder {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	return moduleLinkBuilder(bi.Main.Path, BuildRevision())
+}
+
+// moduleLinkBuilder builds links for the frames of the module, which may be in a
+// subdirectory of its repository
+func moduleLinkBuilder(modulePath, revision string) LinkBuilder {
+	// the repository root is host/owner/repo for all supported hosts
+	parts := strings.SplitN(modulePath, "/", 4)
+	if len(parts) < 3 {
+		return nil
+	}
+	repoRoot := strings.Join(parts[:3], "/")
+	repoURL := "https://" + repoRoot
+
+	var lb LinkBuilder
+	switch parts[0] {
+	case "github.com":
+		lb = GitHubLinks(repoURL, repoRoot, revision)
+	case "gitlab.com":
+		lb = GitLabLinks(repoURL, repoRoot, revision)
+	case "bitbucket.org":
+		lb = BitbucketLinks(repoURL, repoRoot, revision)
+	default:
+		return nil
+	}
+
+	// import paths mirror the directories of the repository, except for the major version
+	moduleDir := modulePath
+	if len(parts) == 4 {
+		moduleDir = trimMajorVersion(modulePath)
+	}
+
+	return func(src LinkSource) string {
+		if !hasPathPrefix(src.ImportPath, modulePath) {
+			return ""
+		}
+
+		src.ImportPath = moduleDir + strings.TrimPrefix(src.ImportPath, modulePath)
+		return lb(src)
+	}
+}
+
+// trimMajorVersion removes the /vN suffix of a module path, for N of at least 2
+func trimMajorVersion(modulePath string) string {
+	i := strings.LastIndex(modulePath, "/")
+	version := modulePath[i+1:]
+	if len(version) < 2 || version[0] != 'v' || version[1] == '0' {
+		return modulePath
+	}
+
+	n, convErr := strconv.Atoi(version[1:])
+	if convErr != nil || n < 2 {
+		return modulePath
+	}
+
+	return modulePath[:i]
+}
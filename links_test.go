@@ -0,0 +1,106 @@
+package e
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkBuilders(t *testing.T) {
+	src := LinkSource{
+		ImportPath: "github.com/acme/app/server",
+		File:       "server.go",
+		AbsPath:    "/src/app/server/server.go",
+		Line:       10,
+	}
+	vendorSrc := LinkSource{
+		ImportPath: "github.com/acme/application",
+		File:       "app.go",
+		AbsPath:    "/go/pkg/mod/github.com/acme/application/app.go",
+		Line:       20,
+	}
+	const rev = "ab15cc7c4cf7ed21fcaa8957ef390dbdbc7e1816"
+
+	tests := []struct {
+		name       string
+		lb         LinkBuilder
+		want       string
+		wantVendor string
+	}{
+		{
+			"github",
+			GitHubLinks("https://github.com/acme/app", "github.com/acme/app", rev),
+			"https://github.com/acme/app/blob/" + rev + "/server/server.go#L10",
+			"",
+		},
+		{
+			"gitlab",
+			GitLabLinks("https://gitlab.com/acme/app/", "github.com/acme/app", rev),
+			"https://gitlab.com/acme/app/-/blob/" + rev + "/server/server.go#L10",
+			"",
+		},
+		{
+			"bitbucket",
+			BitbucketLinks("https://bitbucket.org/acme/app", "github.com/acme/app", rev),
+			"https://bitbucket.org/acme/app/src/" + rev + "/server/server.go#lines-10",
+			"",
+		},
+		{
+			"gitea",
+			GiteaLinks("https://gitea.acme.com/acme/app", "github.com/acme/app", rev),
+			"https://gitea.acme.com/acme/app/src/commit/" + rev + "/server/server.go#L10",
+			"",
+		},
+		{
+			"repo root is the package",
+			GitHubLinks("https://github.com/acme/app", "github.com/acme/app/server", rev),
+			"https://github.com/acme/app/blob/" + rev + "/server.go#L10",
+			"",
+		},
+		{
+			"vscode",
+			VSCodeLinks(),
+			"vscode://file/src/app/server/server.go:10",
+			"vscode://file/go/pkg/mod/github.com/acme/application/app.go:20",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.lb(src))
+			assert.Equal(t, tt.wantVendor, tt.lb(vendorSrc))
+		})
+	}
+}
+
+func TestModuleLinkBuilder(t *testing.T) {
+	const rev = "main"
+
+	tests := []struct {
+		name       string
+		modulePath string
+		importPath string
+		want       string
+	}{
+		{"repo root", "github.com/acme/app", "github.com/acme/app/server",
+			"https://github.com/acme/app/blob/main/server/server.go#L10"},
+		{"major version", "github.com/acme/app/v2", "github.com/acme/app/v2/server",
+			"https://github.com/acme/app/blob/main/server/server.go#L10"},
+		{"subdirectory", "gitlab.com/acme/mono/tools/v3", "gitlab.com/acme/mono/tools/v3",
+			"https://gitlab.com/acme/mono/-/blob/main/tools/server.go#L10"},
+		{"v1 is a directory", "bitbucket.org/acme/app/v1", "bitbucket.org/acme/app/v1/server",
+			"https://bitbucket.org/acme/app/src/main/v1/server/server.go#lines-10"},
+		{"repo named like a version", "github.com/acme/v2", "github.com/acme/v2/server",
+			"https://github.com/acme/v2/blob/main/server/server.go#L10"},
+		{"outside the module", "github.com/acme/app/v2", "github.com/acme/app/server", ""},
+		{"dependency", "github.com/acme/app", "github.com/acme/application", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := moduleLinkBuilder(tt.modulePath, rev)
+			assert.Equal(t, tt.want, lb(LinkSource{ImportPath: tt.importPath, File: "server.go", Line: 10}))
+		})
+	}
+
+	assert.Nil(t, moduleLinkBuilder("example.com/acme/app", rev))
+	assert.Nil(t, moduleLinkBuilder("app", rev))
+}
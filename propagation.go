@@ -0,0 +1,185 @@
+package e
+
+import (
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// Propagation selects what is carried across gRPC calls beyond the code, message and
+// details. Nothing is propagated by default. A server sends it per call, see
+// ServerPropagation, and a client accepts it from the servers it calls, see
+// SetAcceptPropagation, so only services that trust each other should enable it.
+type Propagation struct {
+	// Frames are the error's frames, with their messages and scrubbed vars, followed by
+	// the remote frames it received. They're sent in an errdetails.DebugInfo, which
+	// like any DebugInfo shouldn't be returned to untrusted clients, and shown after the
+	// receiving error's own frames by Error() and in the Sentry event.
+	Frames bool
+
+	// Semantics are whether the error is retriable, infra or not reported, its level
+	// and its scrubbed tags. They're sent in an errdetails.ErrorInfo with the
+	// PropagationDomain, and override those the receiver derives from the status code.
+	Semantics bool
+}
+
+// PropagationDomain is reserved for the details carrying frames and semantics. They're
+// stripped from the details by StatusToError, whether or not they're accepted.
+const PropagationDomain = "github.com/nozzle/e"
+
+// remoteFramesDetail marks the DebugInfo details carrying frames
+const remoteFramesDetail = PropagationDomain + ": remote frames"
+
+// the ErrorInfo carrying semantics has this reason, and the tags are prefixed in its metadata
+const (
+	semanticsReason    = "SEMANTICS"
+	semanticsTagPrefix = "tag_"
+)
+
+// maxRemoteFrames bounds the frames sent in a status, which travels in the trailers
+const maxRemoteFrames = 64
+
+// SetAcceptPropagation sets what the default client accepts from the servers it calls,
+// which is used by StatusToError and by client interceptors without ClientInterceptorClient
+func SetAcceptPropagation(p Propagation) {
+	DefaultClient().SetAcceptPropagation(p)
+}
+
+// WithAcceptPropagation sets what the client accepts from the servers it calls, see
+// SetAcceptPropagation
+func WithAcceptPropagation(p Propagation) ClientOption {
+	return func(cl *Client) {
+		cl.SetAcceptPropagation(p)
+	}
+}
+
+// SetAcceptPropagation sets what the client accepts from the servers it calls
+func (cl *Client) SetAcceptPropagation(p Propagation) {
+	cl.propagationMu.Lock()
+	cl.acceptPropagation = p
+	cl.propagationMu.Unlock()
+}
+
+func (cl *Client) getAcceptPropagation() Propagation {
+	cl.propagationMu.RLock()
+	defer cl.propagationMu.RUnlock()
+
+	return cl.acceptPropagation
+}
+
+// framesDetail encodes the frames of the error, and those it received, into a
+// DebugInfo. Absolute paths are left out, and vars are scrubbed and truncated, but
+// function names, files and lines are still exposed, so it's only sent to callers
+// that ServerPropagation trusts with them.
+func (err *Err) framesDetail() *errdetails.DebugInfo {
+	cl := err.cl()
+	debugInfo := &errdetails.DebugInfo{Detail: remoteFramesDetail}
+
+	for _, fs := range []stackframes{err.frames, err.remoteFrames} {
+		for _, f := range fs {
+			if len(debugInfo.StackEntries) == maxRemoteFrames {
+				return debugInfo
+			}
+
+			jf := f.toJSON(cl.scrub)
+			jf.AbsPath = ""
+			jf.Details = nil
+			for k, raw := range jf.Vars {
+				if len(raw) > maxLogStringLen {
+					jf.Vars[k], _ = json.Marshal(string(raw[:maxLogStringLen]))
+				}
+			}
+
+			b, marshalErr := json.Marshal(jf)
+			if marshalErr != nil {
+				log.Println("unable to marshal remote frame " + marshalErr.Error())
+				continue
+			}
+			debugInfo.StackEntries = append(debugInfo.StackEntries, string(b))
+		}
+	}
+
+	return debugInfo
+}
+
+// isRemoteFrames returns whether the detail is a DebugInfo carrying frames
+func isRemoteFrames(detail proto.Message) bool {
+	debugInfo, ok := detail.(*errdetails.DebugInfo)
+	return ok && debugInfo.GetDetail() == remoteFramesDetail
+}
+
+// decodeRemoteFrames decodes the frames of a DebugInfo sent by framesDetail
+func decodeRemoteFrames(debugInfo *errdetails.DebugInfo) stackframes {
+	fs := make(stackframes, 0, len(debugInfo.GetStackEntries()))
+	for _, entry := range debugInfo.GetStackEntries() {
+		jf := &jsonFrame{}
+		if unmarshalErr := json.Unmarshal([]byte(entry), jf); unmarshalErr != nil {
+			log.Println("unable to unmarshal remote frame " + unmarshalErr.Error())
+			continue
+		}
+		fs = append(fs, jf.toFrame())
+	}
+
+	return fs
+}
+
+// semanticsDetail encodes the semantics of the error into an ErrorInfo
+func (err *Err) semanticsDetail() *errdetails.ErrorInfo {
+	metadata := map[string]string{
+		"level":     err.Level.String(),
+		"retriable": strconv.FormatBool(err.isRetriable),
+		"infra":     strconv.FormatBool(err.isInfra),
+		"noReport":  strconv.FormatBool(err.noReport),
+	}
+
+	for k, v := range err.cl().scrub.tags(err.tags) {
+		metadata[semanticsTagPrefix+k] = v
+	}
+
+	return &errdetails.ErrorInfo{
+		Reason:   semanticsReason,
+		Domain:   PropagationDomain,
+		Metadata: metadata,
+	}
+}
+
+// isSemantics returns whether the detail is an ErrorInfo in the reserved domain
+func isSemantics(detail proto.Message) bool {
+	errorInfo, ok := detail.(*errdetails.ErrorInfo)
+	return ok && errorInfo.GetDomain() == PropagationDomain
+}
+
+// semantics restores the semantics encoded by semanticsDetail. Invalid values are
+// ignored, leaving those derived from the status.
+func semantics(errorInfo *errdetails.ErrorInfo) WrapOption {
+	return func(err *Err) {
+		for k, v := range errorInfo.GetMetadata() {
+			switch k {
+			case "level":
+				if level, ok := levelFromString(v); ok {
+					err.Level = level
+				}
+			case "retriable":
+				if b, parseErr := strconv.ParseBool(v); parseErr == nil {
+					err.isRetriable = b
+				}
+			case "infra":
+				if b, parseErr := strconv.ParseBool(v); parseErr == nil {
+					err.isInfra = b
+				}
+			case "noReport":
+				if b, parseErr := strconv.ParseBool(v); parseErr == nil {
+					err.noReport = b
+				}
+			default:
+				if strings.HasPrefix(k, semanticsTagPrefix) {
+					Tag(strings.TrimPrefix(k, semanticsTagPrefix), v)(err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package e
+
+import (
+	"context"
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newRemoteErr() *Err {
+	return New("db down", Code(codes.Unavailable), With("query", "select 1"), With("password", "hunter2"),
+		DebugInfo("pool exhausted"))
+}
+
+func TestPropagateFrames(t *testing.T) {
+	c := context.Background()
+	internal := NewClient(WithAcceptPropagation(Propagation{Frames: true}))
+	sendFrames := Propagation{Frames: true}
+
+	// nothing is sent by default
+	remoteErr := newRemoteErr()
+	remoteErr.resolveFrames()
+	require.Len(t, remoteErr.GRPCStatus().Details(), 1)
+
+	// frames that aren't accepted are dropped
+	st := remoteErr.grpcStatus(sendFrames)
+	require.Len(t, st.Details(), 2)
+	err := StatusToError(c, st).(*Err)
+	assert.Empty(t, err.remoteFrames)
+	if details := err.Details(); assert.Len(t, details, 1) {
+		assert.Equal(t, "pool exhausted", details[0].(*errdetails.DebugInfo).GetDetail())
+	}
+
+	err = statusToError(c, st, internal.getAcceptPropagation())
+	assert.Len(t, err.Details(), 1)
+	require.Len(t, err.remoteFrames, len(remoteErr.frames))
+	remote := err.remoteFrames[0]
+	assert.Equal(t, "newRemoteErr", remote.fn)
+	assert.Equal(t, remoteErr.frames[0].line, remote.line)
+	assert.Empty(t, remote.absPath)
+	assert.Equal(t, "select 1", remote.vars["query"])
+	assert.Equal(t, RedactedMask, remote.vars["password"])
+
+	msg := err.Error()
+	assert.Contains(t, msg, "--- REMOTE STACK FRAMES ---")
+	assert.Contains(t, msg, "e.newRemoteErr")
+	assert.NotContains(t, msg, "hunter2")
+
+	// the remote frames precede the error's own exception
+	exceptions := err.sentryExceptions()
+	require.Len(t, exceptions, 2)
+	frames := exceptions[0].Stacktrace.Frames
+	assert.Equal(t, "newRemoteErr", frames[len(frames)-1].Function)
+
+	// the remote frames are passed on to the next service after the error's own
+	err = Wrap(err)
+	err.resolveFrames()
+	err = statusToError(c, err.grpcStatus(sendFrames), internal.getAcceptPropagation())
+	assert.Len(t, err.remoteFrames, len(remoteErr.frames)*2)
+	assert.Equal(t, "newRemoteErr", err.remoteFrames[len(remoteErr.frames)].fn)
+
+	b, marshalErr := json.Marshal(err)
+	require.NoError(t, marshalErr)
+	unmarshaled := &Err{}
+	require.NoError(t, json.Unmarshal(b, unmarshaled))
+	assert.Len(t, unmarshaled.remoteFrames, len(err.remoteFrames))
+}
+
+func TestPropagateFramesLimit(t *testing.T) {
+	err := New("deep")
+	err.resolveFrames()
+	for len(err.frames) <= maxRemoteFrames {
+		err.frames = append(err.frames, err.frames[0])
+	}
+
+	assert.Len(t, err.framesDetail().GetStackEntries(), maxRemoteFrames)
+}
+
+func TestPropagateSemantics(t *testing.T) {
+	c := context.Background()
+	internal := NewClient(WithAcceptPropagation(Propagation{Semantics: true}))
+	sendSemantics := Propagation{Semantics: true}
+
+	remoteErr := New("quota exceeded", Code(codes.ResourceExhausted), NotRetriable(), Critical(), Infra(), NoReport(),
+		Tag("tenant", "acme"), ErrorInfo("QUOTA", "acme.com", nil))
+
+	// callers the server doesn't propagate to don't see the semantics
+	err := statusToError(c, remoteErr.GRPCStatus(), internal.getAcceptPropagation())
+	assert.True(t, err.isRetriable)
+	assert.Equal(t, LevelError, err.Level)
+	assert.Empty(t, err.tags)
+
+	// semantics that aren't trusted are dropped
+	st := remoteErr.grpcStatus(sendSemantics)
+	require.Len(t, st.Details(), 2)
+	err = StatusToError(c, st).(*Err)
+	assert.True(t, err.isRetriable)
+	if details := err.Details(); assert.Len(t, details, 1) {
+		assert.Equal(t, "acme.com", details[0].(*errdetails.ErrorInfo).GetDomain())
+	}
+
+	err = statusToError(c, st, internal.getAcceptPropagation())
+	assert.Equal(t, codes.ResourceExhausted, err.Code())
+	assert.False(t, err.isRetriable)
+	assert.Equal(t, LevelCritical, err.Level)
+	assert.True(t, err.isInfra)
+	assert.True(t, err.noReport)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, err.tags)
+	assert.Len(t, err.Details(), 1)
+
+	// the semantics override the RetryInfo
+	remoteErr = New("overloaded", RetryAfter(time.Second), NotRetriable())
+	err = statusToError(c, remoteErr.grpcStatus(sendSemantics), internal.getAcceptPropagation())
+	assert.False(t, err.isRetriable)
+	d, _ := err.RetryAfter()
+	assert.Equal(t, time.Second, d)
+}
+
+func TestPropagatePerCaller(t *testing.T) {
+	const internalKey = "x-internal"
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(
+		ServerReportPolicy(func(*Err) bool { return false }),
+		// only internal callers get the frames and semantics
+		ServerPropagation(func(c context.Context, method string) Propagation {
+			md, _ := metadata.FromIncomingContext(c)
+			if len(md.Get(internalKey)) > 0 {
+				return Propagation{Frames: true, Semantics: true}
+			}
+			return Propagation{}
+		}),
+	)))
+	healthpb.RegisterHealthServer(srv, failingHealthServer{})
+	go srv.Serve(lis) //nolint:errcheck
+	t.Cleanup(srv.Stop)
+
+	internal := NewClient(WithAcceptPropagation(Propagation{Frames: true, Semantics: true}))
+	conn, dialErr := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(c context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(c)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(ClientInterceptorClient(internal))),
+	)
+	require.NoError(t, dialErr)
+	t.Cleanup(func() { conn.Close() })
+	client := healthpb.NewHealthClient(conn)
+
+	_, rawErr := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	err, ok := rawErr.(*Err)
+	require.True(t, ok)
+	assert.Empty(t, err.remoteFrames)
+	assert.Empty(t, err.tags)
+	assert.Same(t, internal, err.cl())
+
+	c := metadata.AppendToOutgoingContext(context.Background(), internalKey, "1")
+	_, rawErr = client.Check(c, &healthpb.HealthCheckRequest{})
+	err, ok = rawErr.(*Err)
+	require.True(t, ok)
+	if assert.NotEmpty(t, err.remoteFrames) {
+		assert.Equal(t, "failingHealthServer.Check", err.remoteFrames[0].fn)
+	}
+	assert.Equal(t, "/grpc.health.v1.Health/Check", err.tags["grpcMethod"])
+	assert.Len(t, err.Details(), 1)
+}
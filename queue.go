@@ -0,0 +1,347 @@
+package e
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// OverflowPolicy determines which report is dropped when the report queue is full
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the report being queued, keeping the first reports of an incident
+	DropNewest OverflowPolicy = iota + 1
+	// DropOldest drops the report that has been queued the longest, keeping the latest reports
+	DropOldest
+)
+
+// QueueConfig configures the queue errors wait in until a worker sends them to the
+// client's reporters
+type QueueConfig struct {
+	// Size is the number of reports that can be queued, 1000 by default
+	Size int
+	// Workers is the number of reports sent concurrently, 4 by default
+	Workers int
+	// Overflow determines which report is dropped when the queue is full, DropNewest by default
+	Overflow OverflowPolicy
+}
+
+// DefaultQueueConfig returns the queue configuration used when WithReportQueue isn't set
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Size:     1000,
+		Workers:  4,
+		Overflow: DropNewest,
+	}
+}
+
+// WithReportQueue configures the client's report queue. The queue of a client can't
+// be changed once it's created, so to configure the default client's queue, replace
+// it with SetDefaultClient.
+func WithReportQueue(qc QueueConfig) ClientOption {
+	return func(cl *Client) {
+		def := DefaultQueueConfig()
+		if qc.Size <= 0 {
+			qc.Size = def.Size
+		}
+		if qc.Workers <= 0 {
+			qc.Workers = def.Workers
+		}
+		if qc.Overflow == 0 {
+			qc.Overflow = def.Overflow
+		}
+
+		cl.queue = newReportQueue(cl, qc)
+	}
+}
+
+// ReportStats counts what happened to the errors reported with a client
+type ReportStats struct {
+	// Queued is the number of reports waiting for a worker
+	Queued int
+	// Delivered is the number of reports handed to every reporter
+	Delivered uint64
+	// Dropped is the number of reports dropped because the queue was full
+	Dropped uint64
+	// Failed is the number of times a reporter returned an error
+	Failed uint64
+}
+
+// GetReportStats returns the report counters of the default client
+func GetReportStats() ReportStats {
+	return DefaultClient().ReportStats()
+}
+
+// ReportStats returns the client's report counters
+func (cl *Client) ReportStats() ReportStats {
+	q := cl.queue
+
+	q.mu.Lock()
+	queued := len(q.jobs)
+	q.mu.Unlock()
+
+	return ReportStats{
+		Queued:    queued,
+		Delivered: atomic.LoadUint64(&q.delivered),
+		Dropped:   atomic.LoadUint64(&q.dropped),
+		Failed:    atomic.LoadUint64(&q.failed),
+	}
+}
+
+type reportJob struct {
+	c   context.Context
+	err *Err
+}
+
+// reportQueue is a bounded FIFO queue drained by a fixed number of workers, which
+// are started with the first report and stopped by close
+type reportQueue struct {
+	cl *Client
+	qc QueueConfig
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	jobs     []reportJob
+	inFlight int
+	started  bool
+	closed   bool
+	workers  sync.WaitGroup
+
+	// idle is closed whenever nothing is queued or in flight, including reports
+	// delivered synchronously with Wait
+	idle chan struct{}
+
+	delivered uint64
+	dropped   uint64
+	failed    uint64
+}
+
+func newReportQueue(cl *Client, qc QueueConfig) *reportQueue {
+	q := &reportQueue{
+		cl:   cl,
+		qc:   qc,
+		idle: make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	close(q.idle)
+
+	return q
+}
+
+// push queues the report, dropping one according to the overflow policy if the queue
+// is full. Once the queue is closed, reports are delivered synchronously instead.
+func (q *reportQueue) push(job reportJob) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.deliverSync(job.c, job.err)
+		return
+	}
+	defer q.mu.Unlock()
+
+	if !q.started {
+		q.started = true
+		q.workers.Add(q.qc.Workers)
+		for i := 0; i < q.qc.Workers; i++ {
+			go q.work()
+		}
+	}
+
+	if len(q.jobs) >= q.qc.Size {
+		atomic.AddUint64(&q.dropped, 1)
+		if q.qc.Overflow == DropNewest {
+			return
+		}
+		q.jobs[0] = reportJob{}
+		q.jobs = q.jobs[1:]
+	}
+
+	if len(q.jobs) == 0 && q.inFlight == 0 {
+		q.idle = make(chan struct{})
+	}
+
+	q.jobs = append(q.jobs, job)
+	q.cond.Signal()
+}
+
+// work delivers queued reports until the queue is closed and drained
+func (q *reportQueue) work() {
+	defer q.workers.Done()
+
+	for {
+		q.mu.Lock()
+		for len(q.jobs) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.jobs) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		job := q.jobs[0]
+		q.jobs[0] = reportJob{}
+		q.jobs = q.jobs[1:]
+		q.inFlight++
+		q.mu.Unlock()
+
+		q.deliver(job.c, job.err)
+		q.finish()
+	}
+}
+
+// deliverSync delivers a report on the calling goroutine, counting it as in flight
+// so that wait doesn't return before it's sent
+func (q *reportQueue) deliverSync(c context.Context, err *Err) {
+	q.mu.Lock()
+	if len(q.jobs) == 0 && q.inFlight == 0 {
+		q.idle = make(chan struct{})
+	}
+	q.inFlight++
+	q.mu.Unlock()
+
+	q.deliver(c, err)
+	q.finish()
+}
+
+// finish marks a delivery as done, and the queue as idle if it was the last one
+func (q *reportQueue) finish() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.inFlight--
+	if len(q.jobs) == 0 && q.inFlight == 0 {
+		close(q.idle)
+	}
+}
+
+// close stops the workers once they've drained the queue, waiting for them to exit
+// and for synchronous deliveries to finish, or the context to be done
+func (q *reportQueue) close(c context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		// reports delivered synchronously may still be in flight
+		return q.wait(c)
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// wait blocks until nothing is queued or in flight, or the context is done
+func (q *reportQueue) wait(c context.Context) error {
+	q.mu.Lock()
+	idle := q.idle
+	q.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// deliver fans the report out to every registered reporter, waiting for all of them
+func (q *reportQueue) deliver(c context.Context, err *Err) {
+	wg := sync.WaitGroup{}
+
+	for _, r := range q.cl.reporters.list() {
+		wg.Add(1)
+		go func(r namedReporter) {
+			if reportErr := r.Report(c, err); reportErr != nil {
+				atomic.AddUint64(&q.failed, 1)
+				log.Println("error reported to " + r.name + " failed: " + reportErr.Error())
+			}
+			wg.Done()
+		}(r)
+	}
+
+	wg.Wait()
+	atomic.AddUint64(&q.delivered, 1)
+}
+
+// snapshot copies the error for reporting in the background, so that it can be wrapped
+// further while it waits in the queue. Frames must be resolved.
+func (err *Err) snapshot() *Err {
+	snap := &Err{
+		rootErr:         err.rootErr,
+		pcs:             err.pcs,
+		rawStack:        err.rawStack,
+		runtimeStats:    err.runtimeStats,
+		framesResolved:  err.framesResolved,
+		currentFrameIdx: err.currentFrameIdx,
+		Level:           err.Level,
+		code:            err.code,
+		isRetriable:     err.isRetriable,
+		retryAfter:      err.retryAfter,
+		noReport:        err.noReport,
+		isPanic:         err.isPanic,
+		isInfra:         err.isInfra,
+		isRemote:        err.isRemote,
+		skipFrames:      err.skipFrames,
+		fromHandler:     err.fromHandler,
+		shouldWait:      err.shouldWait,
+		sentryClient:    err.sentryClient,
+		labels:          err.labels,
+		reported:        atomic.LoadInt32(&err.reported),
+		fingerprint:     err.fingerprint,
+		suppressed:      err.suppressed,
+	}
+
+	snap.client.Store(err.client.Load())
+	snap.frames = snapshotFrames(err.frames)
+	snap.unknownFrames = snapshotFrames(err.unknownFrames)
+	snap.remoteFrames = snapshotFrames(err.remoteFrames)
+	for i, f := range err.frames {
+		if f == err.topAppFrame {
+			snap.topAppFrame = snap.frames[i]
+		}
+	}
+
+	if err.tags != nil {
+		snap.tags = make(map[string]string, len(err.tags))
+		for k, v := range err.tags {
+			snap.tags[k] = v
+		}
+	}
+
+	for _, child := range err.children {
+		snap.children = append(snap.children, child.snapshot())
+	}
+
+	return snap
+}
+
+func snapshotFrames(fs stackframes) stackframes {
+	if fs == nil {
+		return nil
+	}
+
+	snap := make(stackframes, len(fs))
+	for i, f := range fs {
+		fCopy := *f
+		if f.vars != nil {
+			fCopy.vars = make(map[string]interface{}, len(f.vars))
+			for k, v := range f.vars {
+				fCopy.vars[k] = v
+			}
+		}
+		fCopy.errDetails = append([]proto.Message(nil), f.errDetails...)
+		snap[i] = &fCopy
+	}
+
+	return snap
+}
@@ -0,0 +1,151 @@
+package e
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingReporter holds every report until it's released
+type blockingReporter struct {
+	testReporter
+	started chan struct{}
+	release chan struct{}
+	fail    bool
+}
+
+func (br *blockingReporter) Report(c context.Context, err *Err) error {
+	br.started <- struct{}{}
+	<-br.release
+	_ = br.testReporter.Report(c, err)
+
+	if br.fail {
+		return errors.New("reporter failed")
+	}
+	return nil
+}
+
+func TestReportQueueOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow OverflowPolicy
+		want     []string
+	}{
+		{"drop newest", DropNewest, []string{"first", "second", "third"}},
+		{"drop oldest", DropOldest, []string{"first", "third", "fourth"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := &blockingReporter{started: make(chan struct{}, 10), release: make(chan struct{})}
+			cl := NewClient(
+				WithReporter("blocking", br),
+				WithReportQueue(QueueConfig{Size: 2, Workers: 1, Overflow: tt.overflow}),
+			)
+
+			c := context.Background()
+			New("first").Report(c, ReportWithClient(cl))
+			<-br.started
+
+			// the worker is busy, so only two of the next three fit in the queue
+			for _, msg := range []string{"second", "third", "fourth"} {
+				New(msg).Report(c, ReportWithClient(cl))
+			}
+			assert.Equal(t, ReportStats{Queued: 2, Dropped: 1}, cl.ReportStats())
+
+			close(br.release)
+			cl.Flush(c)
+
+			var got []string
+			for _, err := range br.reported {
+				got = append(got, err.rootErr.Error())
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, ReportStats{Delivered: 3, Dropped: 1}, cl.ReportStats())
+		})
+	}
+}
+
+func TestReportQueueWait(t *testing.T) {
+	br := &blockingReporter{started: make(chan struct{}, 10), release: make(chan struct{}), fail: true}
+	cl := NewClient(WithReporter("blocking", br))
+
+	// the report is delivered before Report returns, and the context isn't detached
+	close(br.release)
+	New("some error").Report(context.Background(), ReportWithClient(cl), Wait())
+	assert.Len(t, br.reported, 1)
+	assert.Equal(t, ReportStats{Delivered: 1, Failed: 1}, cl.ReportStats())
+}
+
+func TestReportQueueSnapshot(t *testing.T) {
+	br := &blockingReporter{started: make(chan struct{}, 10), release: make(chan struct{})}
+	cl := NewClient(WithReporter("blocking", br))
+
+	c := context.Background()
+	err := New("some error", Tag("k", "v"), With("var", 1))
+	err.Report(c, ReportWithClient(cl))
+	<-br.started
+
+	// wrapping the error while it's being reported doesn't change the report
+	Wrap(err, Tag("k", "changed"), With("var", 2), Msg("outer"))
+	close(br.release)
+	cl.Flush(c)
+
+	if assert.Len(t, br.reported, 1) {
+		got := br.reported[0]
+		assert.NotSame(t, err, got)
+		assert.Equal(t, "some error", got.shortString())
+		assert.Equal(t, map[string]string{"k": "v"}, got.tags)
+		assert.Equal(t, 1, got.topAppFrame.vars["var"])
+	}
+}
+
+func TestReportQueueFlushWaitsForWait(t *testing.T) {
+	br := &blockingReporter{started: make(chan struct{}, 10), release: make(chan struct{})}
+	cl := NewClient(WithReporter("blocking", br))
+
+	done := make(chan struct{})
+	go func() {
+		New("some error").Report(context.Background(), ReportWithClient(cl), Wait())
+		close(done)
+	}()
+	<-br.started
+
+	// the synchronous delivery is still in flight
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, cl.Flush(c))
+
+	close(br.release)
+	assert.NoError(t, cl.Flush(context.Background()))
+	assert.Len(t, br.reported, 1)
+	<-done
+}
+
+func TestClientClose(t *testing.T) {
+	br := &blockingReporter{started: make(chan struct{}, 10), release: make(chan struct{})}
+	cl := NewClient(WithReporter("blocking", br), WithReportQueue(QueueConfig{Size: 10, Workers: 2}))
+
+	c := context.Background()
+	New("first").Report(c, ReportWithClient(cl))
+	New("second").Report(c, ReportWithClient(cl))
+	<-br.started
+
+	// the workers are busy, so Close gives up
+	timeout, cancel := context.WithTimeout(c, 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, cl.Close(timeout))
+
+	// the queue is drained before the workers stop
+	close(br.release)
+	assert.NoError(t, cl.Close(c))
+	assert.Len(t, br.reported, 2)
+	assert.Equal(t, ReportStats{Delivered: 2}, cl.ReportStats())
+
+	// errors reported after Close are delivered synchronously
+	New("third").Report(c, ReportWithClient(cl))
+	assert.Len(t, br.reported, 3)
+	assert.NoError(t, cl.Flush(c))
+}
@@ -2,60 +2,61 @@ package e
 
 import (
 	"context"
-	"log"
-	"sync"
-	"time"
+	"errors"
+	"sort"
+	"strings"
 
 	"github.com/getsentry/sentry-go"
 )
 
-// Flush sends all buffered errors to servers before returning. Should always be
-// called before program exit to ensure no lost errors.
-func Flush(c context.Context) {
-	wg := sync.WaitGroup{}
-
-	if errorClient != nil {
-		wg.Add(1)
-		go func() {
-			errorClient.Flush()
-			wg.Done()
-		}()
+// Flush waits for the default client's report queue to drain and then sends all errors
+// buffered by its reporters before returning. Should always be called before program
+// exit to ensure no lost errors. It returns early once the context is done, with an
+// error naming the reporters that didn't drain.
+func Flush(c context.Context) error {
+	return DefaultClient().Flush(c)
+}
+
+// flushReporters flushes every reporter concurrently, returning an error naming the
+// reporters that failed or hadn't finished when the context was done
+func flushReporters(c context.Context, rs []namedReporter) error {
+	type flushResult struct {
+		name string
+		err  error
 	}
 
-	if sentryClient != nil {
-		wg.Add(1)
-		go func() {
-			completed := sentryClient.Flush(10 * time.Second)
-			if !completed {
-				log.Println("sentryClient flush did NOT complete")
-			}
-			wg.Done()
-		}()
+	results := make(chan flushResult, len(rs))
+	pending := make(map[string]bool, len(rs))
+	for _, r := range rs {
+		pending[r.name] = true
+		go func(r namedReporter) {
+			results <- flushResult{r.name, r.Flush(c)}
+		}(r)
 	}
 
-	if sentryCriticalClient != nil {
-		wg.Add(1)
-		go func() {
-			completed := sentryCriticalClient.Flush(10 * time.Second)
-			if !completed {
-				log.Println("sentryCriticalClient flush did NOT complete")
+	var incomplete []string
+	for len(pending) > 0 {
+		select {
+		case res := <-results:
+			delete(pending, res.name)
+			if res.err != nil {
+				incomplete = append(incomplete, res.name+" ("+res.err.Error()+")")
 			}
-			wg.Done()
-		}()
-	}
 
-	if sentryInfraClient != nil {
-		wg.Add(1)
-		go func() {
-			completed := sentryInfraClient.Flush(10 * time.Second)
-			if !completed {
-				log.Println("sentryInfraClient flush did NOT complete")
+		case <-c.Done():
+			for name := range pending {
+				incomplete = append(incomplete, name+" ("+c.Err().Error()+")")
 			}
-			wg.Done()
-		}()
+			pending = nil
+		}
 	}
 
-	wg.Wait()
+	if len(incomplete) > 0 {
+		sort.Strings(incomplete)
+		return errors.New("reporters did not drain: " + strings.Join(incomplete, ", "))
+	}
+
+	return nil
 }
 
 // A ReportOption let you determine report behavior
@@ -77,14 +78,18 @@ func ReportIsHandler() ReportOption {
 	}
 }
 
-// ReportWithSentryClient reports the error with a custom Sentry client
+// ReportWithSentryClient reports the error with a custom Sentry client. This is
+// honored by the registered SentryReporter, so one must have been set up, e.g.
+// via SetSentryClient.
 func ReportWithSentryClient(cl *sentry.Client) ReportOption {
 	return func(err *Err) {
 		err.sentryClient = cl
 	}
 }
 
-// Report ships your error when you aren't returning through a handler
+// Report ships your error when you aren't returning through a handler. The error
+// is queued and sent to the reporters in the background, unless the Wait option is
+// set. See WithReportQueue.
 func (err *Err) Report(c context.Context, opts ...ReportOption) {
 	for _, opt := range opts {
 		opt(err)
@@ -93,27 +98,38 @@ func (err *Err) Report(c context.Context, opts ...ReportOption) {
 	if !err.shouldReport(c) {
 		return
 	}
+	err.markReported()
+
+	// resolve the stack before reporters run concurrently, and to find similar errors
+	err.resolveFrames()
 
-	// ship data to Sentry and Google Error Reporting, waiting if necessary
-	wg := sync.WaitGroup{}
-
-	// send to Sentry
-	wg.Add(1)
-	go func() {
-		err.reportToSentry(c)
-		wg.Done()
-	}()
-
-	// send to Google Error Reporting
-	if errorClient != nil {
-		wg.Add(1)
-		go func() {
-			err.reportToStackdriver(c)
-			wg.Done()
-		}()
+	cl := err.cl()
+	allowed, suppressed := cl.sampler.allow(err)
+	if !allowed {
+		return
+	}
+	err.suppressed = suppressed
+	err.labels = cl.labels(c)
+
+	// capture runtime state before reporters run concurrently. Statistics captured at
+	// wrap are only kept for clients that want them, as another client may have.
+	switch cl.getRuntimeStatsMode() {
+	case RuntimeStatsAtWrap:
+		// captured when the error was first wrapped
+	case RuntimeStatsAtReport:
+		err.runtimeStats = captureRuntimeStats()
+	default:
+		err.runtimeStats = nil
+	}
+
+	if err.shouldWait {
+		cl.queue.deliverSync(c, err)
+		return
 	}
 
-	wg.Wait()
+	// the caller may keep wrapping the error, and its context may be canceled as soon
+	// as Report returns, while the report waits in the queue
+	cl.queue.push(reportJob{c: context.WithoutCancel(c), err: err.snapshot()})
 }
 
 func (err *Err) shouldReport(c context.Context) bool {
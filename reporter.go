@@ -0,0 +1,127 @@
+package e
+
+import (
+	"context"
+	"sync"
+)
+
+// Reporter ships errors to an external error tracking service. Implementations
+// must be safe for concurrent use.
+type Reporter interface {
+	// Report sends the error. Implementations may buffer the error and send it
+	// asynchronously, as long as Flush delivers everything that was buffered.
+	Report(c context.Context, err *Err) error
+
+	// Flush blocks until all buffered errors have been sent
+	Flush(c context.Context) error
+}
+
+// names of the built in reporters, which can be used with RemoveReporter
+const (
+	SentryReporterName         = "sentry"
+	ErrorReportingReporterName = "errorreporting"
+)
+
+// reporterRegistry keeps the registered reporters in the order they were added
+type reporterRegistry struct {
+	mu        sync.RWMutex
+	names     []string
+	reporters map[string]Reporter
+}
+
+// AddReporter registers a reporter on the default client that every reported error
+// will be sent to. Adding a reporter with the name of an existing one replaces it.
+func AddReporter(name string, r Reporter) {
+	DefaultClient().AddReporter(name, r)
+}
+
+// RemoveReporter unregisters the reporter with the given name from the default
+// client, if there is one
+func RemoveReporter(name string) {
+	DefaultClient().RemoveReporter(name)
+}
+
+// GetReporter returns the reporter registered on the default client with the
+// given name, or nil if there isn't one
+func GetReporter(name string) Reporter {
+	return DefaultClient().GetReporter(name)
+}
+
+func (rr *reporterRegistry) add(name string, r Reporter) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.reporters == nil {
+		rr.reporters = make(map[string]Reporter)
+	}
+
+	if _, ok := rr.reporters[name]; !ok {
+		rr.names = append(rr.names, name)
+	}
+	rr.reporters[name] = r
+}
+
+// getOrAdd returns the reporter registered with the given name, registering the one
+// returned by newFn if there isn't one
+func (rr *reporterRegistry) getOrAdd(name string, newFn func() Reporter) Reporter {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if r, ok := rr.reporters[name]; ok {
+		return r
+	}
+
+	if rr.reporters == nil {
+		rr.reporters = make(map[string]Reporter)
+	}
+
+	r := newFn()
+	rr.names = append(rr.names, name)
+	rr.reporters[name] = r
+
+	return r
+}
+
+func (rr *reporterRegistry) remove(name string) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if _, ok := rr.reporters[name]; !ok {
+		return
+	}
+
+	delete(rr.reporters, name)
+	for i := range rr.names {
+		if rr.names[i] == name {
+			rr.names = append(rr.names[:i:i], rr.names[i+1:]...)
+			break
+		}
+	}
+}
+
+func (rr *reporterRegistry) get(name string) Reporter {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	return rr.reporters[name]
+}
+
+// namedReporter pairs a reporter with its registered name for logging
+type namedReporter struct {
+	name string
+	Reporter
+}
+
+// list returns a snapshot of the registered reporters, so that fanning out
+// doesn't hold the lock during network calls
+func (rr *reporterRegistry) list() []namedReporter {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	list := make([]namedReporter, 0, len(rr.names))
+	for _, name := range rr.names {
+		list = append(list, namedReporter{name: name, Reporter: rr.reporters[name]})
+	}
+
+	return list
+}
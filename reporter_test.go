@@ -0,0 +1,64 @@
+package e
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testReporter struct {
+	mu       sync.Mutex
+	reported []*Err
+	flushed  int
+}
+
+func (tr *testReporter) Report(c context.Context, err *Err) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.reported = append(tr.reported, err)
+	return nil
+}
+
+func (tr *testReporter) Flush(c context.Context) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.flushed++
+	return nil
+}
+
+// drain waits for the reports queued on the client to be delivered, without flushing
+// the reporters
+func drain(cl *Client) {
+	_ = cl.queue.wait(context.Background())
+}
+
+func TestReporters(t *testing.T) {
+	c := context.Background()
+	r1, r2 := &testReporter{}, &testReporter{}
+
+	AddReporter("r1", r1)
+	AddReporter("r2", r2)
+	defer RemoveReporter("r1")
+	defer RemoveReporter("r2")
+
+	err := New("some error")
+	err.Report(c)
+	New("not reported", NoReport()).Report(c)
+	drain(DefaultClient())
+
+	assert.Equal(t, []*Err{err}, r1.reported)
+	assert.Equal(t, []*Err{err}, r2.reported)
+
+	RemoveReporter("r1")
+	New("another error").Report(c)
+	Flush(c)
+
+	assert.Len(t, r1.reported, 1)
+	assert.Len(t, r2.reported, 2)
+	assert.Equal(t, 0, r1.flushed)
+	assert.Equal(t, 1, r2.flushed)
+	assert.Nil(t, GetReporter("r1"))
+	assert.Equal(t, r2, GetReporter("r2"))
+}
@@ -0,0 +1,231 @@
+package e
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RuntimeStatsMode determines when runtime statistics are captured for an error
+type RuntimeStatsMode int32
+
+const (
+	// RuntimeStatsOff never captures runtime statistics
+	RuntimeStatsOff RuntimeStatsMode = iota + 1
+	// RuntimeStatsAtReport captures runtime statistics when an error is reported,
+	// so errors that are never reported don't pay for them. This is the default.
+	RuntimeStatsAtReport
+	// RuntimeStatsAtWrap captures runtime statistics when an error is first wrapped,
+	// which reflects the state of the process closer to when the error happened
+	RuntimeStatsAtWrap
+)
+
+// SetRuntimeStats sets when the default client captures runtime statistics and
+// attaches them to reports
+func SetRuntimeStats(mode RuntimeStatsMode) {
+	DefaultClient().SetRuntimeStats(mode)
+}
+
+// wrapStatsClients counts the clients using RuntimeStatsAtWrap, since errors capture
+// the statistics before the client that reports them is known
+var wrapStatsClients int32
+
+// runtime/metrics samples that are read for every capture. Unlike runtime.ReadMemStats,
+// reading these doesn't stop the world.
+const (
+	metricHeapAlloc    = "/memory/classes/heap/objects:bytes"
+	metricHeapObjects  = "/gc/heap/objects:objects"
+	metricHeapGoal     = "/gc/heap/goal:bytes"
+	metricHeapFree     = "/memory/classes/heap/free:bytes"
+	metricHeapReleased = "/memory/classes/heap/released:bytes"
+	metricTotalMemory  = "/memory/classes/total:bytes"
+	metricTotalAlloc   = "/gc/heap/allocs:bytes"
+	metricMallocs      = "/gc/heap/allocs:objects"
+	metricFrees        = "/gc/heap/frees:objects"
+	metricNumGC        = "/gc/cycles/total:gc-cycles"
+	metricGCPauses     = "/gc/pauses:seconds"
+	metricGoroutines   = "/sched/goroutines:goroutines"
+)
+
+var runtimeMetricNames = []string{
+	metricHeapAlloc,
+	metricHeapObjects,
+	metricHeapGoal,
+	metricHeapFree,
+	metricHeapReleased,
+	metricTotalMemory,
+	metricTotalAlloc,
+	metricMallocs,
+	metricFrees,
+	metricNumGC,
+	metricGCPauses,
+	metricGoroutines,
+}
+
+// gcPauseQuantiles are the quantiles of the GC pause histogram that are attached to reports
+var gcPauseQuantiles = []float64{0.5, 0.9, 0.99, 1}
+
+// runtimeStats is a snapshot of the process at the time of capture
+type runtimeStats struct {
+	gomaxprocs int
+
+	// uint64 values from runtime/metrics, keyed by metric name
+	values map[string]uint64
+
+	// GC pause durations in seconds at gcPauseQuantiles
+	gcPauses []float64
+
+	// the memory limit of the cgroup the process runs in, or 0 if there isn't one
+	cgroupMemoryLimit uint64
+}
+
+// captureRuntimeStats reads the current runtime statistics
+func captureRuntimeStats() *runtimeStats {
+	samples := make([]metrics.Sample, len(runtimeMetricNames))
+	for i, name := range runtimeMetricNames {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+
+	rs := &runtimeStats{
+		gomaxprocs:        runtime.GOMAXPROCS(0),
+		values:            make(map[string]uint64, len(samples)),
+		cgroupMemoryLimit: cgroupMemoryLimit(),
+	}
+
+	// metrics that aren't supported by the running Go version have KindBad and are left out
+	for _, s := range samples {
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			rs.values[s.Name] = s.Value.Uint64()
+
+		case metrics.KindFloat64Histogram:
+			if s.Name == metricGCPauses {
+				rs.gcPauses = histogramQuantiles(s.Value.Float64Histogram(), gcPauseQuantiles)
+			}
+		}
+	}
+
+	return rs
+}
+
+// histogramQuantiles returns the upper bound of the bucket each quantile falls into,
+// or nil if the histogram is empty
+func histogramQuantiles(h *metrics.Float64Histogram, quantiles []float64) []float64 {
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total == 0 {
+		return nil
+	}
+
+	result := make([]float64, 0, len(quantiles))
+	var cumulative uint64
+	bucket := 0
+	for _, q := range quantiles {
+		threshold := uint64(math.Ceil(q * float64(total)))
+		for ; bucket < len(h.Counts); bucket++ {
+			if cumulative+h.Counts[bucket] >= threshold {
+				break
+			}
+			cumulative += h.Counts[bucket]
+		}
+
+		// bucket i covers [Buckets[i], Buckets[i+1]), the upper bound of the last one may be +Inf
+		upper := h.Buckets[bucket+1]
+		if math.IsInf(upper, 1) {
+			upper = h.Buckets[bucket]
+		}
+		result = append(result, upper)
+	}
+
+	return result
+}
+
+// addExtra adds the runtime statistics to the extra data of a report
+func (rs *runtimeStats) addExtra(m map[string]interface{}) {
+	m["Runtime.Goroutines"] = rs.values[metricGoroutines]
+	m["Runtime.GOMAXPROCS"] = rs.gomaxprocs
+
+	for k, name := range map[string]string{
+		"Runtime.Memory.Total":      metricTotalMemory,
+		"Runtime.Memory.TotalAlloc": metricTotalAlloc,
+		"Runtime.Memory.Mallocs":    metricMallocs,
+		"Runtime.Memory.Frees":      metricFrees,
+		"Runtime.Heap.Alloc":        metricHeapAlloc,
+		"Runtime.Heap.Objects":      metricHeapObjects,
+		"Runtime.Heap.Goal":         metricHeapGoal,
+		"Runtime.Heap.Free":         metricHeapFree,
+		"Runtime.Heap.Released":     metricHeapReleased,
+		"Runtime.GC.NumGC":          metricNumGC,
+	} {
+		if v, ok := rs.values[name]; ok {
+			m[k] = v
+		}
+	}
+
+	for i, pause := range rs.gcPauses {
+		name := "max"
+		if q := gcPauseQuantiles[i]; q < 1 {
+			name = "p" + strconv.FormatFloat(q*100, 'f', -1, 64)
+		}
+		m["Runtime.GC.Pause."+name] = strconv.FormatFloat(pause*1e6, 'f', 1, 64) + "µs"
+	}
+
+	if rs.cgroupMemoryLimit > 0 {
+		m["Runtime.Cgroup.MemoryLimit"] = rs.cgroupMemoryLimit
+	}
+}
+
+var (
+	cgroupMemoryLimitOnce  sync.Once
+	cgroupMemoryLimitValue uint64
+)
+
+// cgroupMemoryLimit returns the memory limit of the cgroup the process runs in, or 0
+// if it isn't limited. The limit is only read once, since it rarely changes.
+func cgroupMemoryLimit() uint64 {
+	cgroupMemoryLimitOnce.Do(func() {
+		cgroupMemoryLimitValue = readCgroupMemoryLimit(
+			"/sys/fs/cgroup/memory.max",                   // cgroup v2
+			"/sys/fs/cgroup/memory/memory.limit_in_bytes", // cgroup v1
+		)
+	})
+
+	return cgroupMemoryLimitValue
+}
+
+// readCgroupMemoryLimit returns the limit from the first file that can be read
+func readCgroupMemoryLimit(paths ...string) uint64 {
+	for _, p := range paths {
+		b, readErr := os.ReadFile(p)
+		if readErr != nil {
+			continue
+		}
+
+		// cgroup v2 reports "max" when unlimited
+		s := strings.TrimSpace(string(b))
+		if s == "max" {
+			return 0
+		}
+
+		limit, parseErr := strconv.ParseUint(s, 10, 64)
+		if parseErr != nil {
+			return 0
+		}
+
+		// cgroup v1 reports a huge page aligned number when unlimited
+		if limit >= math.MaxInt64/4096*4096 {
+			return 0
+		}
+
+		return limit
+	}
+
+	return 0
+}
@@ -0,0 +1,116 @@
+package e
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeStatsMode(t *testing.T) {
+	defer SetRuntimeStats(RuntimeStatsAtReport)
+
+	tr := &testReporter{}
+	AddReporter("runtimeStats", tr)
+	defer RemoveReporter("runtimeStats")
+
+	tests := []struct {
+		name     string
+		mode     RuntimeStatsMode
+		atWrap   bool
+		atReport bool
+	}{
+		{"off", RuntimeStatsOff, false, false},
+		{"at report", RuntimeStatsAtReport, false, true},
+		{"at wrap", RuntimeStatsAtWrap, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRuntimeStats(tt.mode)
+
+			err := New("some error")
+			assert.Equal(t, tt.atWrap, err.runtimeStats != nil)
+
+			err.Report(context.Background())
+			assert.Equal(t, tt.atReport, err.runtimeStats != nil)
+		})
+	}
+}
+
+func TestRuntimeStatsClients(t *testing.T) {
+	atWrap := NewClient(WithReporter("test", &testReporter{}), WithRuntimeStats(RuntimeStatsAtWrap))
+	defer atWrap.SetRuntimeStats(RuntimeStatsAtReport)
+	atReport := NewClient(WithReporter("test", &testReporter{}))
+	off := NewClient(WithReporter("test", &testReporter{}), WithRuntimeStats(RuntimeStatsOff))
+
+	// any client at wrap makes errors capture the statistics
+	err := New("some error")
+	wrapStats := err.runtimeStats
+	assert.NotNil(t, wrapStats)
+
+	err.Report(context.Background(), ReportWithClient(atWrap), Wait())
+	assert.Same(t, wrapStats, err.runtimeStats)
+
+	err = New("some error")
+	err.Report(context.Background(), ReportWithClient(atReport), Wait())
+	assert.NotNil(t, err.runtimeStats)
+	assert.NotSame(t, wrapStats, err.runtimeStats)
+
+	err = New("some error")
+	err.Report(context.Background(), ReportWithClient(off), Wait())
+	assert.Nil(t, err.runtimeStats)
+
+	// setting the same mode twice is counted once
+	atWrap.SetRuntimeStats(RuntimeStatsAtWrap)
+	atWrap.SetRuntimeStats(RuntimeStatsAtReport)
+	assert.Nil(t, New("some error").runtimeStats)
+}
+
+func TestRuntimeStatsExtra(t *testing.T) {
+	rs := captureRuntimeStats()
+
+	m := map[string]interface{}{}
+	rs.addExtra(m)
+
+	assert.Equal(t, runtime.GOMAXPROCS(0), m["Runtime.GOMAXPROCS"])
+	assert.NotZero(t, m["Runtime.Goroutines"])
+	assert.NotZero(t, m["Runtime.Heap.Alloc"])
+	assert.NotZero(t, m["Runtime.Memory.Total"])
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{5, 4, 0, 1},
+		Buckets: []float64{0, 1, 2, 3, math.Inf(1)},
+	}
+
+	assert.Equal(t, []float64{1, 2, 3}, histogramQuantiles(h, []float64{0.5, 0.9, 1}))
+	assert.Nil(t, histogramQuantiles(&metrics.Float64Histogram{
+		Counts:  []uint64{0},
+		Buckets: []float64{0, 1},
+	}, gcPauseQuantiles))
+}
+
+func TestReadCgroupMemoryLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+		return p
+	}
+
+	limited := writeFile("limited", "536870912\n")
+	unlimitedV2 := writeFile("unlimitedV2", "max\n")
+	unlimitedV1 := writeFile("unlimitedV1", "9223372036854771712\n")
+	missing := filepath.Join(dir, "missing")
+
+	assert.Equal(t, uint64(536870912), readCgroupMemoryLimit(missing, limited))
+	assert.Equal(t, uint64(0), readCgroupMemoryLimit(unlimitedV2, limited))
+	assert.Equal(t, uint64(0), readCgroupMemoryLimit(unlimitedV1))
+	assert.Equal(t, uint64(0), readCgroupMemoryLimit(missing))
+}
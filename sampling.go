@@ -0,0 +1,189 @@
+package e
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// SampleRule throttles the reports of matching errors, so that a hot loop returning
+// the same error doesn't flood the reporters. Every field that is set must match, and
+// a rule without criteria matches every error. The first matching rule applies.
+//
+// Errors are counted separately by fingerprint, code and level, and the number of
+// reports suppressed since the last one is attached to the next report that gets through.
+type SampleRule struct {
+	// Codes matches errors with any of the codes
+	Codes []codes.Code
+	// Levels matches errors with any of the levels
+	Levels []Level
+
+	// SampleRate is the fraction of matching errors that are reported, e.g. 0.1 for
+	// one in ten. Zero, like one, reports all of them.
+	SampleRate float64
+
+	// Limit is the number of reports allowed per Interval, which are refilled
+	// continuously like a token bucket. Zero doesn't limit the reports.
+	Limit    int
+	Interval time.Duration
+}
+
+// maxSampleBuckets bounds the memory used to count errors by fingerprint
+const maxSampleBuckets = 10000
+
+// SetSampleRules replaces the rules the default client throttles reports with. There
+// are none by default, so every error is reported.
+func SetSampleRules(rules ...SampleRule) {
+	DefaultClient().SetSampleRules(rules...)
+}
+
+type sampleBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+}
+
+type sampler struct {
+	mu      sync.Mutex
+	rules   []SampleRule
+	buckets map[string]*sampleBucket
+
+	// now and random are replaced in tests
+	now    func() time.Time
+	random func() float64
+}
+
+func newSampler(rules []SampleRule) *sampler {
+	s := &sampler{
+		now:    time.Now,
+		random: rand.Float64,
+	}
+	s.set(rules...)
+	return s
+}
+
+func (s *sampler) set(rules ...SampleRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = rules
+	s.buckets = make(map[string]*sampleBucket)
+}
+
+func (sr *SampleRule) matches(err *Err) bool {
+	if len(sr.Codes) > 0 {
+		var found bool
+		for _, code := range sr.Codes {
+			if err.code == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(sr.Levels) > 0 {
+		var found bool
+		for _, level := range sr.Levels {
+			if err.Level == level {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allow returns whether the error should be reported, and how many similar reports
+// were suppressed since the last one that was allowed. Frames must be resolved.
+func (s *sampler) allow(err *Err) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rule *SampleRule
+	for i := range s.rules {
+		if s.rules[i].matches(err) {
+			rule = &s.rules[i]
+			break
+		}
+	}
+
+	if rule == nil {
+		return true, 0
+	}
+
+	key := err.sampleKey()
+	b, ok := s.buckets[key]
+	now := s.now()
+	if !ok {
+		if len(s.buckets) >= maxSampleBuckets {
+			s.prune()
+		}
+		b = &sampleBucket{tokens: float64(rule.Limit), last: now}
+		s.buckets[key] = b
+	}
+
+	if !rule.take(b, now) || (rule.SampleRate > 0 && rule.SampleRate < 1 && s.random() >= rule.SampleRate) {
+		b.suppressed++
+		return false, 0
+	}
+
+	suppressed := b.suppressed
+	b.suppressed = 0
+
+	return true, suppressed
+}
+
+// take refills the bucket for the time since it was last used and takes a token
+func (sr *SampleRule) take(b *sampleBucket, now time.Time) bool {
+	if sr.Limit <= 0 || sr.Interval <= 0 {
+		return true
+	}
+
+	b.tokens += float64(sr.Limit) * float64(now.Sub(b.last)) / float64(sr.Interval)
+	if b.tokens > float64(sr.Limit) {
+		b.tokens = float64(sr.Limit)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// prune drops the buckets without suppressed reports, which only limit the next
+// reports of their errors, and then every bucket if that wasn't enough
+func (s *sampler) prune() {
+	for key, b := range s.buckets {
+		if b.suppressed == 0 {
+			delete(s.buckets, key)
+		}
+	}
+
+	if len(s.buckets) >= maxSampleBuckets {
+		s.buckets = make(map[string]*sampleBucket)
+	}
+}
+
+// Suppressed returns how many similar reports were suppressed by the sample rules
+// since the last one before this error was reported, see SetSampleRules
+func (err *Err) Suppressed() int {
+	return err.suppressed
+}
+
+// sampleKey identifies similar errors by fingerprint, code and level
+func (err *Err) sampleKey() string {
+	return strings.Join(err.Fingerprint(), "|") + "|" + err.code.String() + "|" + err.Level.String()
+}
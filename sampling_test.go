@@ -0,0 +1,87 @@
+package e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func sampledHelper(code codes.Code) *Err {
+	return New("hot loop", Code(code))
+}
+
+func TestSampleRulesLimit(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(
+		WithReporter("sampling", tr),
+		WithSampleRules(SampleRule{Codes: []codes.Code{codes.Unavailable}, Limit: 2, Interval: time.Minute}),
+	)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cl.sampler.now = func() time.Time { return now }
+
+	c := context.Background()
+	for i := 0; i < 10; i++ {
+		sampledHelper(codes.Unavailable).Report(c, ReportWithClient(cl))
+	}
+	drain(cl)
+	assert.Len(t, tr.reported, 2)
+
+	// errors with other codes don't match the rule
+	sampledHelper(codes.Internal).Report(c, ReportWithClient(cl))
+	drain(cl)
+	assert.Len(t, tr.reported, 3)
+
+	// errors created elsewhere are counted separately
+	New("elsewhere", Code(codes.Unavailable)).Report(c, ReportWithClient(cl))
+	drain(cl)
+	assert.Len(t, tr.reported, 4)
+	tr.reported = tr.reported[:3]
+
+	// half the interval refills one token, and the next report carries the suppressed count
+	now = now.Add(30 * time.Second)
+	sampledHelper(codes.Unavailable).Report(c, ReportWithClient(cl))
+	sampledHelper(codes.Unavailable).Report(c, ReportWithClient(cl))
+	drain(cl)
+	if assert.Len(t, tr.reported, 4) {
+		err := tr.reported[3]
+		assert.Equal(t, 8, err.Suppressed())
+		assert.Equal(t, "8 similar events suppressed", err.sentryExtra()["suppressed"])
+	}
+
+	now = now.Add(time.Minute)
+	sampledHelper(codes.Unavailable).Report(c, ReportWithClient(cl))
+	drain(cl)
+	if assert.Len(t, tr.reported, 5) {
+		assert.Equal(t, 1, tr.reported[4].Suppressed())
+	}
+}
+
+func TestSampleRulesRate(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(
+		WithReporter("sampling", tr),
+		WithSampleRules(SampleRule{Levels: []Level{LevelWarning}, SampleRate: 0.25}),
+	)
+
+	var n int
+	cl.sampler.random = func() float64 {
+		n++
+		return float64(n%4) / 4
+	}
+
+	c := context.Background()
+	for i := 0; i < 8; i++ {
+		New("warning", Warning()).Report(c, ReportWithClient(cl))
+	}
+	drain(cl)
+	assert.Len(t, tr.reported, 2)
+
+	// errors at other levels don't match the rule
+	New("error").Report(c, ReportWithClient(cl))
+	drain(cl)
+	assert.Len(t, tr.reported, 3)
+}
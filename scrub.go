@@ -0,0 +1,421 @@
+package e
+
+import (
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// RedactedMask replaces every value that is scrubbed
+const RedactedMask = "[REDACTED]"
+
+// Redacted marks a value that must never leave the process, e.g.
+// With("password", e.Redacted{V: password}). It's masked wherever it's printed,
+// marshaled or logged, regardless of the scrub rules.
+type Redacted struct {
+	V interface{}
+}
+
+// String fulfills the fmt.Stringer interface
+func (Redacted) String() string {
+	return RedactedMask
+}
+
+// GoString fulfills the fmt.GoStringer interface, which is used by %#v
+func (Redacted) GoString() string {
+	return RedactedMask
+}
+
+// Format fulfills the fmt.Formatter interface, masking the value for every verb
+func (Redacted) Format(s fmt.State, verb rune) {
+	fmt.Fprint(s, RedactedMask)
+}
+
+// MarshalJSON fulfills the json.Marshaler interface
+func (Redacted) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + RedactedMask + `"`), nil
+}
+
+// LogValue fulfills the slog.LogValuer interface
+func (Redacted) LogValue() slog.Value {
+	return slog.StringValue(RedactedMask)
+}
+
+// ScrubRules determine what is masked before errors leave the process, i.e. in
+// Error(), fmt verbs, JSON, slog and reports
+type ScrubRules struct {
+	// EnvAllowlist are the names of the environment variables that are shipped with
+	// reports, where '*' matches any run of characters, e.g. "K_*"
+	EnvAllowlist []string
+
+	// Keys mask whole vars, tags, request headers, cookies and query parameters
+	// whose key matches any of them
+	Keys []*regexp.Regexp
+
+	// Values mask the parts of vars, tags, frame messages and request data that
+	// match any of them
+	Values []*regexp.Regexp
+}
+
+// DefaultScrubRules returns the rules used when SetScrubRules hasn't been called.
+// No environment variables are shipped, keys that look like credentials are masked,
+// as are bearer tokens and passwords in URLs.
+func DefaultScrubRules() ScrubRules {
+	return ScrubRules{
+		Keys: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)passw(or)?d|secret|token|api[-_]?key|^auth$|authorization|auth[-_]?token|credential|cookie|session|private[-_]?key`),
+		},
+		Values: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)bearer\s+[^\s"']+`),
+			regexp.MustCompile(`[^:/?#@\s]+:[^:/?#@\s]+@`),
+		},
+	}
+}
+
+// SetScrubRules replaces the rules the default client scrubs errors with
+func SetScrubRules(sr ScrubRules) {
+	DefaultClient().SetScrubRules(sr)
+}
+
+type scrubber struct {
+	mu     sync.RWMutex
+	env    []*regexp.Regexp
+	keys   []*regexp.Regexp
+	values []*regexp.Regexp
+}
+
+func newScrubber(sr ScrubRules) *scrubber {
+	s := &scrubber{}
+	s.set(sr)
+	return s
+}
+
+func (s *scrubber) set(sr ScrubRules) {
+	env := make([]*regexp.Regexp, 0, len(sr.EnvAllowlist))
+	for _, name := range sr.EnvAllowlist {
+		env = append(env, globToRegexp(name))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.env = env
+	s.keys = sr.Keys
+	s.values = sr.Values
+}
+
+// allowEnv returns whether the environment variable can be shipped
+func (s *scrubber) allowEnv(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, re := range s.env {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maskKey returns whether everything stored under the key is masked
+func (s *scrubber) maskKey(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, re := range s.keys {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// str masks the parts of str that match the value rules
+func (s *scrubber) str(str string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, re := range s.values {
+		str = re.ReplaceAllString(str, RedactedMask)
+	}
+
+	return str
+}
+
+// maxScrubDepth bounds how deep scrubbing follows pointers and containers
+const maxScrubDepth = 8
+
+// value scrubs a var. Strings, bytes and values that print as strings are scrubbed by
+// value, pointers are followed, maps and slices are scrubbed element by element, and
+// structs by their JSON. Values are only replaced if something was scrubbed.
+func (s *scrubber) value(key string, v interface{}) interface{} {
+	scrubbed, _ := s.scrubValue(key, v, 0)
+	return scrubbed
+}
+
+// scrubValue scrubs a value, returning whether anything was scrubbed
+func (s *scrubber) scrubValue(key string, v interface{}, depth int) (interface{}, bool) {
+	if s.maskKey(key) {
+		return RedactedMask, true
+	}
+
+	scrubStr := func(str string) (string, bool) {
+		scrubbed := s.str(str)
+		return scrubbed, scrubbed != str
+	}
+
+	switch t := v.(type) {
+	case nil:
+		return v, false
+
+	case Redacted, *Redacted:
+		return RedactedMask, true
+
+	case string:
+		return scrubStr(t)
+
+	case json.RawMessage:
+		// valid JSON is scrubbed like the values it decodes to, so keys are masked too
+		var decoded interface{}
+		if depth < maxScrubDepth && json.Unmarshal(t, &decoded) == nil {
+			if scrubbed, changed := s.scrubValue("", decoded, depth+1); changed {
+				if b, marshalErr := json.Marshal(scrubbed); marshalErr == nil {
+					return json.RawMessage(b), true
+				}
+			}
+			return v, false
+		}
+
+		if scrubbed, changed := scrubStr(string(t)); changed {
+			return scrubbed, true
+		}
+
+	case []byte:
+		if scrubbed, changed := scrubStr(string(t)); changed {
+			return scrubbed, true
+		}
+
+	case error:
+		if scrubbed, changed := scrubStr(t.Error()); changed {
+			return scrubbed, true
+		}
+
+	case fmt.Stringer:
+		if scrubbed, changed := scrubStr(t.String()); changed {
+			return scrubbed, true
+		}
+
+	case map[string]string:
+		if scrubbed := s.tags(t); !reflect.DeepEqual(scrubbed, t) {
+			return scrubbed, true
+		}
+
+	case map[string]interface{}:
+		if depth < maxScrubDepth {
+			return s.scrubMap(t, depth+1)
+		}
+
+	case []string:
+		scrubbed := make([]string, len(t))
+		var changed bool
+		for i, str := range t {
+			var strChanged bool
+			scrubbed[i], strChanged = scrubStr(str)
+			changed = changed || strChanged
+		}
+		if changed {
+			return scrubbed, true
+		}
+
+	case []interface{}:
+		if depth < maxScrubDepth {
+			scrubbed := make([]interface{}, len(t))
+			var changed bool
+			for i, el := range t {
+				var elChanged bool
+				scrubbed[i], elChanged = s.scrubValue("", el, depth+1)
+				changed = changed || elChanged
+			}
+			if changed {
+				return scrubbed, true
+			}
+		}
+
+	default:
+		if depth >= maxScrubDepth {
+			return v, false
+		}
+
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if !rv.IsNil() {
+				if scrubbed, changed := s.scrubValue("", rv.Elem().Interface(), depth+1); changed {
+					return scrubbed, true
+				}
+			}
+
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			// other composites are scrubbed as the JSON they're marshaled to, and replaced
+			// by the values it decodes to if anything was scrubbed
+			b, marshalErr := json.Marshal(v)
+			if marshalErr != nil {
+				break
+			}
+			var decoded interface{}
+			if json.Unmarshal(b, &decoded) != nil {
+				break
+			}
+			if scrubbed, changed := s.scrubValue("", decoded, depth+1); changed {
+				return scrubbed, true
+			}
+		}
+	}
+
+	return v, false
+}
+
+// scrubMap scrubs the values of a map, masking them by their keys too
+func (s *scrubber) scrubMap(m map[string]interface{}, depth int) (interface{}, bool) {
+	scrubbed := make(map[string]interface{}, len(m))
+	var changed bool
+	for k, v := range m {
+		var vChanged bool
+		scrubbed[k], vChanged = s.scrubValue(k, v, depth)
+		changed = changed || vChanged
+	}
+
+	if !changed {
+		return m, false
+	}
+
+	return scrubbed, true
+}
+
+// vars returns a scrubbed copy of the vars
+func (s *scrubber) vars(vars map[string]interface{}) map[string]interface{} {
+	if vars == nil {
+		return nil
+	}
+
+	scrubbed := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		scrubbed[k] = s.value(k, v)
+	}
+
+	return scrubbed
+}
+
+// tags returns a scrubbed copy of the tags
+func (s *scrubber) tags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+
+	scrubbed := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if s.maskKey(k) {
+			scrubbed[k] = RedactedMask
+		} else {
+			scrubbed[k] = s.str(v)
+		}
+	}
+
+	return scrubbed
+}
+
+// query scrubs a raw URL query
+func (s *scrubber) query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, parseErr := url.ParseQuery(rawQuery)
+	if parseErr != nil {
+		return s.str(rawQuery)
+	}
+
+	for k, vs := range values {
+		for i := range vs {
+			if s.maskKey(k) {
+				vs[i] = RedactedMask
+			} else {
+				vs[i] = s.str(vs[i])
+			}
+		}
+	}
+
+	return values.Encode()
+}
+
+// url scrubs the query and userinfo of a URL
+func (s *scrubber) url(rawURL string) string {
+	u, parseErr := url.Parse(rawURL)
+	if parseErr != nil {
+		return s.str(rawURL)
+	}
+
+	u.User = nil
+	u.RawQuery = s.query(u.RawQuery)
+
+	return s.str(u.String())
+}
+
+// sentryRequest scrubs the request data sent to Sentry
+func (s *scrubber) sentryRequest(req *sentry.Request) *sentry.Request {
+	if req == nil {
+		return nil
+	}
+
+	req.URL = s.url(req.URL)
+	req.QueryString = s.query(req.QueryString)
+	req.Headers = s.tags(req.Headers)
+	if referer, ok := req.Headers["Referer"]; ok {
+		req.Headers["Referer"] = s.url(referer)
+	}
+	if req.Cookies != "" {
+		if s.maskKey("Cookie") {
+			req.Cookies = RedactedMask
+		} else {
+			req.Cookies = s.str(req.Cookies)
+		}
+	}
+
+	return req
+}
+
+// httpRequest returns a scrubbed copy of the request
+func (s *scrubber) httpRequest(r *http.Request) *http.Request {
+	if r == nil {
+		return nil
+	}
+
+	scrubbed := r.Clone(r.Context())
+	if scrubbed.URL != nil {
+		scrubbed.URL.RawQuery = s.query(r.URL.RawQuery)
+		scrubbed.URL.User = nil
+	}
+	for k, vs := range scrubbed.Header {
+		for i := range vs {
+			if s.maskKey(k) {
+				vs[i] = RedactedMask
+			} else {
+				vs[i] = s.str(vs[i])
+			}
+		}
+	}
+	if referer := scrubbed.Header.Get("Referer"); referer != "" {
+		scrubbed.Header.Set("Referer", s.url(referer))
+	}
+
+	return scrubbed
+}
@@ -0,0 +1,185 @@
+package e
+
+import (
+	"context"
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubVarsAndMsgs(t *testing.T) {
+	err := New("some error",
+		Msg("dialing postgres://app:hunter2@db:5432"),
+		With("password", "hunter2"),
+		With("header", "Bearer abc.def"),
+		With("user", "jane"),
+		With("pin", Redacted{V: 1234}),
+		Tag("apiKey", "key"),
+		Tag("region", "us"),
+	)
+
+	errStr := err.Error()
+	assert.NotContains(t, errStr, "hunter2")
+	assert.NotContains(t, errStr, "abc.def")
+	assert.NotContains(t, errStr, "1234")
+	assert.Contains(t, errStr, `+ user: "jane"`)
+	assert.Contains(t, errStr, "*** dialing postgres://[REDACTED]db:5432")
+
+	assert.Equal(t, "dialing postgres://[REDACTED]db:5432: some error", fmt.Sprint(err))
+	assert.NotContains(t, fmt.Sprintf("%#v", err), "hunter2")
+
+	b, marshalErr := json.Marshal(err)
+	assert.NoError(t, marshalErr)
+	assert.NotContains(t, string(b), "hunter2")
+	assert.NotContains(t, string(b), "1234")
+	assert.Contains(t, string(b), `"region":"us"`)
+	assert.Contains(t, string(b), `"apiKey":"[REDACTED]"`)
+
+	// the error itself keeps the values
+	assert.Equal(t, "hunter2", err.topAppFrame.vars["password"])
+
+	sentryFrames := err.sentryStacktrace().Frames
+	vars := sentryFrames[len(sentryFrames)-1].Vars
+	assert.Equal(t, RedactedMask, vars["password"])
+	assert.Equal(t, "[REDACTED]", vars["header"])
+	assert.Equal(t, "dialing postgres://[REDACTED]db:5432", vars["_Msg"])
+	assert.NotContains(t, err.topAppFrame.vars, "_Msg")
+}
+
+func TestRedacted(t *testing.T) {
+	r := Redacted{V: "secret"}
+
+	assert.Equal(t, RedactedMask, fmt.Sprint(r))
+	assert.Equal(t, RedactedMask, fmt.Sprintf("%#v", r))
+	assert.Equal(t, RedactedMask, fmt.Sprintf("%d", r))
+
+	b, marshalErr := json.Marshal(r)
+	assert.NoError(t, marshalErr)
+	assert.Equal(t, `"[REDACTED]"`, string(b))
+
+	// masked even when the rules match nothing
+	cl := NewClient(WithScrubRules(ScrubRules{}))
+	assert.Equal(t, RedactedMask, cl.scrub.value("k", r))
+	assert.Equal(t, "hunter2", cl.scrub.value("password", "hunter2"))
+}
+
+func TestDefaultScrubKeys(t *testing.T) {
+	cl := NewClient()
+
+	for _, key := range []string{"password", "passwd", "clientSecret", "accessToken", "api_key",
+		"auth", "AUTH", "Authorization", "proxyAuthorization", "auth_token", "X-Auth-Token",
+		"credentials", "Cookie", "sessionID", "private-key"} {
+		assert.True(t, cl.scrub.maskKey(key), key)
+	}
+
+	// keys that merely contain one of the words aren't masked
+	for _, key := range []string{"author", "authority", "oauthProvider", "authenticated", "user", "region", "pass"} {
+		assert.False(t, cl.scrub.maskKey(key), key)
+	}
+}
+
+func TestScrubEnv(t *testing.T) {
+	cl := NewClient()
+	cl.envVars = []envVar{{"K_SERVICE", "api"}, {"DB_PASSWORD", "hunter2"}, {"DATABASE_URL", "postgres://app:hunter2@db"}}
+
+	err := New("some error")
+	err.setClient(cl)
+	extra := err.sentryExtra()
+	for k := range extra {
+		assert.NotRegexp(t, "^env\\.", k)
+	}
+
+	cl.SetScrubRules(ScrubRules{
+		EnvAllowlist: []string{"K_*", "DATABASE_URL"},
+		Values:       DefaultScrubRules().Values,
+	})
+	extra = err.sentryExtra()
+	assert.Equal(t, "api", extra["env.K_SERVICE"])
+	assert.Equal(t, "postgres://[REDACTED]db", extra["env.DATABASE_URL"])
+	assert.NotContains(t, extra, "env.DB_PASSWORD")
+}
+
+func TestScrubRequest(t *testing.T) {
+	cl := NewClient(WithScrubRules(ScrubRules{
+		Keys:   append(DefaultScrubRules().Keys, regexp.MustCompile(`(?i)^ssn$`)),
+		Values: DefaultScrubRules().Values,
+	}))
+
+	req := httptest.NewRequest("GET", "http://example.com/users?ssn=123&page=2&access_token=abc", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("Referer", "http://example.com/login?token=abc")
+
+	err := New("some error", Tag("session", "abc"))
+	err.setClient(cl)
+	err.labels.Request = req
+	err.resolveFrames()
+
+	c := context.Background()
+	tags := err.sentryTags(c)
+	assert.Equal(t, RedactedMask, tags["session"])
+	assert.Equal(t, "access_token=%5BREDACTED%5D&page=2&ssn=%5BREDACTED%5D", tags["rawQuery"])
+	assert.Equal(t, "http://example.com/login?token=%5BREDACTED%5D", tags["referrer"])
+
+	sentryReq := err.sentryEvent(c).Request
+	assert.Equal(t, "access_token=%5BREDACTED%5D&page=2&ssn=%5BREDACTED%5D", sentryReq.QueryString)
+	assert.Equal(t, RedactedMask, sentryReq.Headers["Authorization"])
+	assert.Equal(t, RedactedMask, sentryReq.Cookies)
+	assert.Equal(t, "http://example.com/login?token=%5BREDACTED%5D", sentryReq.Headers["Referer"])
+
+	gcpReq := cl.scrub.httpRequest(req)
+	assert.Equal(t, RedactedMask, gcpReq.Header.Get("Authorization"))
+	assert.Equal(t, "access_token=%5BREDACTED%5D&page=2&ssn=%5BREDACTED%5D", gcpReq.URL.RawQuery)
+
+	// the request itself is untouched
+	assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+	assert.Equal(t, "ssn=123&page=2&access_token=abc", req.URL.RawQuery)
+}
+
+type scrubTestCreds struct {
+	User     string
+	Password string
+	DSN      string
+}
+
+func TestScrubValueTypes(t *testing.T) {
+	s := NewClient().scrub
+	dsn := "postgres://app:hunter2@db"
+	masked := "postgres://[REDACTED]db"
+
+	assert.Equal(t, masked, s.value("v", []byte(dsn)))
+	assert.Equal(t, masked, s.value("v", &dsn))
+	assert.Equal(t, json.RawMessage(`{"dsn":"`+masked+`","token":"[REDACTED]"}`),
+		s.value("v", json.RawMessage(`{"dsn":"`+dsn+`","token":"abc"}`)))
+	assert.Equal(t, masked, s.value("v", json.RawMessage(dsn)))
+
+	assert.Equal(t, map[string]interface{}{"dsn": masked, "apiKey": RedactedMask},
+		s.value("v", map[string]interface{}{"dsn": dsn, "apiKey": "abc"}))
+	assert.Equal(t, map[string]string{"dsn": masked}, s.value("v", map[string]string{"dsn": dsn}))
+	assert.Equal(t, []string{masked}, s.value("v", []string{dsn}))
+	assert.Equal(t, []interface{}{masked, 1.0}, s.value("v", []interface{}{dsn, 1.0}))
+
+	// structs are replaced by the values of their JSON, with their keys masked too
+	creds := scrubTestCreds{User: "jane", Password: "hunter2", DSN: dsn}
+	want := map[string]interface{}{"User": "jane", "Password": RedactedMask, "DSN": masked}
+	assert.Equal(t, want, s.value("v", creds))
+	assert.Equal(t, want, s.value("v", &creds))
+
+	// values without anything to scrub are left alone
+	clean := &struct{ User string }{User: "jane"}
+	assert.Same(t, clean, s.value("v", clean))
+	assert.Equal(t, []byte("plain"), s.value("v", []byte("plain")))
+
+	// and they're scrubbed wherever the vars are rendered
+	err := New("some error", With("dsnBytes", []byte(dsn)), With("dsnPtr", &dsn), With("creds", creds))
+	err.resolveFrames()
+	b, marshalErr := json.Marshal(err)
+	assert.NoError(t, marshalErr)
+	for _, out := range []string{err.Error(), string(b), fmt.Sprint(err.sentryStacktrace().Frames)} {
+		assert.NotContains(t, out, "hunter2")
+	}
+}
@@ -0,0 +1,98 @@
+package e
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+)
+
+// codeSentinel matches any Err with its code, e.g. errors.Is(err, ErrNotFound)
+type codeSentinel struct {
+	code codes.Code
+}
+
+func (s *codeSentinel) Error() string {
+	return "e: " + s.code.String()
+}
+
+// sentinels for every gRPC code but OK, which errors.Is matches against the code of an Err
+var (
+	ErrCanceled           error = &codeSentinel{codes.Canceled}
+	ErrUnknown            error = &codeSentinel{codes.Unknown}
+	ErrInvalidArgument    error = &codeSentinel{codes.InvalidArgument}
+	ErrDeadlineExceeded   error = &codeSentinel{codes.DeadlineExceeded}
+	ErrNotFound           error = &codeSentinel{codes.NotFound}
+	ErrAlreadyExists      error = &codeSentinel{codes.AlreadyExists}
+	ErrPermissionDenied   error = &codeSentinel{codes.PermissionDenied}
+	ErrResourceExhausted  error = &codeSentinel{codes.ResourceExhausted}
+	ErrFailedPrecondition error = &codeSentinel{codes.FailedPrecondition}
+	ErrAborted            error = &codeSentinel{codes.Aborted}
+	ErrOutOfRange         error = &codeSentinel{codes.OutOfRange}
+	ErrUnimplemented      error = &codeSentinel{codes.Unimplemented}
+	ErrInternal           error = &codeSentinel{codes.Internal}
+	ErrUnavailable        error = &codeSentinel{codes.Unavailable}
+	ErrDataLoss           error = &codeSentinel{codes.DataLoss}
+	ErrUnauthenticated    error = &codeSentinel{codes.Unauthenticated}
+)
+
+// kindSentinel matches any Err of a kind, e.g. errors.Is(err, ErrKindInfra)
+type kindSentinel struct {
+	name  string
+	match func(err *Err) bool
+}
+
+func (s *kindSentinel) Error() string {
+	return "e: " + s.name
+}
+
+// sentinels for kinds of errors, which errors.Is matches against the flags of an Err
+var (
+	// ErrKindInfra matches errors wrapped with the Infra option
+	ErrKindInfra error = &kindSentinel{"infra", func(err *Err) bool {
+		return err.isInfra
+	}}
+	// ErrKindPanic matches errors that were recovered from a panic with Recover, or
+	// that were wrapped while panicking
+	ErrKindPanic error = &kindSentinel{"panic", func(err *Err) bool {
+		// panics are also detected from the frames, so the stack has to be resolved
+		err.resolveFrames()
+		return err.isPanic
+	}}
+	// ErrKindNotRetriable matches errors wrapped with the NotRetriable option
+	ErrKindNotRetriable error = &kindSentinel{"not retriable", func(err *Err) bool {
+		return !err.isRetriable
+	}}
+	// ErrKindCritical matches errors with LevelCritical
+	ErrKindCritical error = &kindSentinel{"critical", func(err *Err) bool {
+		return err.Level == LevelCritical
+	}}
+	// ErrKindWarning matches errors with LevelWarning
+	ErrKindWarning error = &kindSentinel{"warning", func(err *Err) bool {
+		return err.Level == LevelWarning
+	}}
+)
+
+// Is fulfills the interface used by errors.Is, matching the code and kind sentinels
+// of this package. The root error is matched by errors.Is through Unwrap.
+func (err *Err) Is(target error) bool {
+	switch t := target.(type) {
+	case *codeSentinel:
+		return err.code == t.code
+
+	case *kindSentinel:
+		return t.match(err)
+
+	default:
+		return false
+	}
+}
+
+// asErr finds the first Err in the chain of rawErr
+func asErr(rawErr error) (*Err, bool) {
+	var err *Err
+	if !errors.As(rawErr, &err) {
+		return nil, false
+	}
+
+	return err, true
+}
@@ -0,0 +1,82 @@
+package e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrIs(t *testing.T) {
+	notFound := fmt.Errorf("loading user: %w", New("no rows", Code(codes.NotFound), NotRetriable()))
+
+	assert.True(t, errors.Is(notFound, ErrNotFound))
+	assert.False(t, errors.Is(notFound, ErrInternal))
+	assert.True(t, errors.Is(notFound, ErrKindNotRetriable))
+	assert.False(t, errors.Is(notFound, ErrKindInfra))
+	assert.False(t, errors.Is(notFound, ErrKindCritical))
+	assert.False(t, errors.Is(errors.New("plain"), ErrUnknown))
+
+	// the root error is still matched through Unwrap
+	assert.True(t, errors.Is(Wrap(io.EOF, Infra(), Warning()), io.EOF))
+	assert.True(t, errors.Is(Wrap(io.EOF, Infra(), Warning()), ErrKindInfra))
+	assert.True(t, errors.Is(Wrap(io.EOF, Infra(), Warning()), ErrKindWarning))
+
+	// context errors get their codes by default
+	assert.True(t, errors.Is(Wrap(context.Canceled), ErrCanceled))
+	assert.True(t, errors.Is(Wrap(context.Canceled), context.Canceled))
+}
+
+func TestErrIsPanic(t *testing.T) {
+	var got *Err
+	func() {
+		defer Recover(context.Background(), RecoverNoLog(), RecoverNoReport(), RecoverNoPanic(),
+			RecoverFunc(func(c context.Context, err *Err) {
+				got = err
+			}))
+		panic("boom")
+	}()
+
+	assert.True(t, errors.Is(got, ErrKindPanic))
+	assert.True(t, errors.Is(got, ErrKindCritical))
+	assert.True(t, errors.Is(got, ErrInternal))
+	assert.False(t, errors.Is(New("some error"), ErrKindPanic))
+
+	// errors wrapped while panicking match whether or not the stack was already resolved
+	for _, render := range []bool{false, true} {
+		var err *Err
+		func() {
+			defer func() {
+				_ = recover()
+				err = New("some error")
+			}()
+			panic("boom")
+		}()
+
+		if render {
+			assert.NotEmpty(t, err.Error())
+		}
+		assert.True(t, errors.Is(err, ErrKindPanic), "rendered first: %t", render)
+	}
+}
+
+func TestFromErrorChain(t *testing.T) {
+	err := fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", New("some error", Code(codes.NotFound), NotRetriable())))
+
+	assert.Equal(t, codes.NotFound, CodeFromError(err))
+	assert.Equal(t, codes.OK, CodeFromError(nil))
+	assert.Equal(t, codes.Unknown, CodeFromError(errors.New("plain")))
+
+	assert.False(t, IsRetriable(err))
+	assert.False(t, IsRetriable(nil))
+	assert.True(t, IsRetriable(errors.New("plain")))
+
+	path, file, line := Location(err)
+	assert.Equal(t, "github.com/nozzle/e", path)
+	assert.Equal(t, "sentinels_test.go", file)
+	assert.NotZero(t, line)
+}
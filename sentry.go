@@ -2,126 +2,245 @@ package e
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"net/http"
+	"errors"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 )
 
-var (
-	sentryClient         *sentry.Client
-	sentryCriticalClient *sentry.Client
-	sentryInfraClient    *sentry.Client
-
-	// this initializes all the environment variables to ship with errors
-	envVars = func() []envVar {
-		environ := os.Environ()
-		vars := make([]envVar, 0, len(environ))
-
-		for _, e := range environ {
-			pair := strings.SplitN(e, "=", 2)
-			ev := envVar{k: pair[0]}
-			if len(pair) > 1 {
-				ev.v = pair[1]
-			}
-			vars = append(vars, ev)
+// environ returns all the environment variables, which are filtered by the scrub
+// rules' allowlist before they're shipped with errors
+func environ() []envVar {
+	environ := os.Environ()
+	vars := make([]envVar, 0, len(environ))
+
+	for _, e := range environ {
+		pair := strings.SplitN(e, "=", 2)
+		ev := envVar{k: pair[0]}
+		if len(pair) > 1 {
+			ev.v = pair[1]
 		}
+		vars = append(vars, ev)
+	}
 
-		return vars
-	}()
-)
+	return vars
+}
 
 type envVar struct {
 	k, v string
 }
 
-// SetSentryClient stores a global reference to a sentry client.
-// Should only be called once at app startup.
+// SentryReporter reports errors to Sentry. Critical and infrastructure errors
+// are sent to their own clients when those are set. The clients must not be
+// changed directly once the reporter is registered, use the Set*Client functions.
+type SentryReporter struct {
+	Client         *sentry.Client
+	CriticalClient *sentry.Client
+	InfraClient    *sentry.Client
+
+	mu sync.RWMutex
+}
+
+// sentryReporter returns the SentryReporter registered on the client, registering
+// a new one if necessary
+func (cl *Client) sentryReporter() *SentryReporter {
+	r := cl.reporters.getOrAdd(SentryReporterName, func() Reporter {
+		return &SentryReporter{}
+	})
+
+	sr, ok := r.(*SentryReporter)
+	if !ok {
+		// a custom reporter was registered with the built in name, so replace it
+		sr = &SentryReporter{}
+		cl.AddReporter(SentryReporterName, sr)
+	}
+
+	return sr
+}
+
+// SetSentryClient stores a reference to a sentry client on the SentryReporter of the
+// default client. Should only be called once at app startup.
 func SetSentryClient(c context.Context, cl *sentry.Client) {
-	sentryClient = cl
+	DefaultClient().SetSentryClient(c, cl)
 }
 
-// SetSentryCriticalClient stores a global reference to a sentry client used only for critical errors.
-// Should only be called once at app startup.
+// SetSentryCriticalClient stores a reference to a sentry client used only for critical errors
+// on the SentryReporter of the default client. Should only be called once at app startup.
 func SetSentryCriticalClient(c context.Context, cl *sentry.Client) {
-	sentryCriticalClient = cl
+	DefaultClient().SetSentryCriticalClient(c, cl)
 }
 
-// SetSentryInfraClient stores a global reference to a sentry client used only for infrastructure errors.
-// Should only be called once at app startup.
+// SetSentryInfraClient stores a reference to a sentry client used only for infrastructure errors
+// on the SentryReporter of the default client. Should only be called once at app startup.
 func SetSentryInfraClient(c context.Context, cl *sentry.Client) {
-	sentryInfraClient = cl
+	DefaultClient().SetSentryInfraClient(c, cl)
+}
+
+// SetSentryClient stores a reference to a sentry client on the client's SentryReporter
+func (cl *Client) SetSentryClient(c context.Context, sentryCl *sentry.Client) {
+	sr := cl.sentryReporter()
+	sr.mu.Lock()
+	sr.Client = sentryCl
+	sr.mu.Unlock()
+}
+
+// SetSentryCriticalClient stores a reference to a sentry client used only for critical
+// errors on the client's SentryReporter
+func (cl *Client) SetSentryCriticalClient(c context.Context, sentryCl *sentry.Client) {
+	sr := cl.sentryReporter()
+	sr.mu.Lock()
+	sr.CriticalClient = sentryCl
+	sr.mu.Unlock()
+}
+
+// SetSentryInfraClient stores a reference to a sentry client used only for infrastructure
+// errors on the client's SentryReporter
+func (cl *Client) SetSentryInfraClient(c context.Context, sentryCl *sentry.Client) {
+	sr := cl.sentryReporter()
+	sr.mu.Lock()
+	sr.InfraClient = sentryCl
+	sr.mu.Unlock()
 }
 
-func (err *Err) reportToSentry(c context.Context) {
+// Report fulfills the Reporter interface
+func (sr *SentryReporter) Report(c context.Context, err *Err) error {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
 	var cl *sentry.Client
 	switch {
 	case err.sentryClient != nil:
 		cl = err.sentryClient
 
 	case err.isInfra:
-		cl = sentryInfraClient
+		cl = sr.InfraClient
 
 	case err.Level == LevelCritical:
-		cl = sentryCriticalClient
+		cl = sr.CriticalClient
 
 	default:
-		cl = sentryClient
+		cl = sr.Client
 	}
 
 	if cl == nil {
-		log.Println("error reported to Sentry without valid client")
-		return
+		return errors.New("no valid Sentry client")
 	}
 
 	cl.CaptureEvent(err.sentryEvent(c), nil, nil)
 	if err.shouldWait {
 		cl.Flush(2 * time.Second)
 	}
+
+	return nil
+}
+
+// defaultFlushTimeout bounds flushes whose context doesn't have a deadline
+const defaultFlushTimeout = 10 * time.Second
+
+// Flush fulfills the Reporter interface, waiting until the context's deadline
+func (sr *SentryReporter) Flush(c context.Context) error {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	var incomplete []string
+
+	for _, nc := range []struct {
+		name string
+		cl   *sentry.Client
+	}{
+		{"sentryClient", sr.Client},
+		{"sentryCriticalClient", sr.CriticalClient},
+		{"sentryInfraClient", sr.InfraClient},
+	} {
+		if nc.cl != nil && !nc.cl.Flush(flushTimeout(c)) {
+			incomplete = append(incomplete, nc.name)
+		}
+	}
+
+	if len(incomplete) > 0 {
+		return errors.New(strings.Join(incomplete, ", ") + " did not complete")
+	}
+
+	return nil
+}
+
+// flushTimeout returns the time left until the context's deadline
+func flushTimeout(c context.Context) time.Duration {
+	deadline, ok := c.Deadline()
+	if !ok {
+		return defaultFlushTimeout
+	}
+
+	return time.Until(deadline)
 }
 
 func (err *Err) sentryEvent(c context.Context) *sentry.Event {
+	cl := err.cl()
+
 	var msg string
 	for _, f := range err.frames {
 		if f.msg != "" {
-			msg = f.msg
+			msg = cl.scrub.str(f.msg)
 			break
 		}
 	}
 
 	var req *sentry.Request
-	// activeRequest := internal.Request(c)
-	// if activeRequest != nil {
-	// 	req = sentry.NewRequest(activeRequest)
-	// }
+	if err.labels.Request != nil {
+		req = cl.scrub.sentryRequest(sentry.NewRequest(err.labels.Request))
+	}
 
 	return &sentry.Event{
 		Extra:   err.sentryExtra(),
 		Level:   err.sentryLevel(),
 		Tags:    err.sentryTags(c),
 		Message: msg,
-		User:    sentry.User{
-			// ID: strconv.FormatInt(ctx.UserID(c), 10),
+		User: sentry.User{
+			ID: err.labels.UserID,
 		},
-		Request: req,
-		Exception: []sentry.Exception{{
+		Request:     req,
+		Exception:   err.sentryExceptions(),
+		Fingerprint: err.Fingerprint(),
+	}
+}
+
+// sentryExceptions returns the exception of the error, preceded by those of any
+// joined errors, since Sentry shows the last exception of a chain as the main one.
+// The remote frames come right before it, as the exception it was caused by.
+func (err *Err) sentryExceptions() []sentry.Exception {
+	var exceptions []sentry.Exception
+	for _, child := range err.children {
+		exceptions = append(exceptions, child.sentryExceptions()...)
+	}
+
+	if len(err.remoteFrames) > 0 {
+		exceptions = append(exceptions, sentry.Exception{
 			Type:       err.rootErr.Error(),
-			Value:      err.githubURL(),
-			Stacktrace: err.sentryStacktrace(),
-		}},
+			Value:      "remote stack frames",
+			Stacktrace: err.sentryRemoteStacktrace(),
+		})
 	}
+
+	return append(exceptions, sentry.Exception{
+		Type:       err.rootErr.Error(),
+		Value:      err.sourceLink(),
+		Stacktrace: err.sentryStacktrace(),
+	})
 }
 
 func (err *Err) sentryTags(c context.Context) map[string]string {
 	// set default error reporting tags
 	errTags := make(map[string]string, 15)
 
-	// set user tags first, so that they can't overwrite built in tags
+	// set current user / tenant / trace context first, so that tags set explicitly
+	// with the Tag option win over the ambient labels
+	err.labels.setTags(errTags)
+
+	// set user tags before the built in tags, so that they can't overwrite them
 	for k, v := range err.tags {
 		setTagIfNotEmpty(errTags, k, v)
 	}
@@ -134,14 +253,6 @@ func (err *Err) sentryTags(c context.Context) map[string]string {
 	// setTagIfNotEmpty(errTags, "region", env.Region)
 	// setTagIfNotEmpty(errTags, "zone", env.Zone)
 
-	// set current user / workspace context
-	// setTagIfNotEmpty(errTags, "workspaceID", strconv.FormatInt(internal.WorkspaceID(c), 10))
-
-	// uID := internal.UserID(c)
-	// if uID != 0 {
-	// 	errTags["userID"] = strconv.FormatInt(uID, 10)
-	// }
-
 	// set main error fields
 	setBoolTag(errTags, "handlerErr", err.fromHandler)
 	setBoolTag(errTags, "isRetriable", err.isRetriable)
@@ -159,16 +270,17 @@ func (err *Err) sentryTags(c context.Context) map[string]string {
 		errTags["level"] = "warning"
 	}
 
-	// add originating stack frame tags
-	setTagIfNotEmpty(errTags, "file", err.topAppFrame.file)
-	setTagIfNotEmpty(errTags, "path", err.topAppFrame.path)
-	setTagIfNotEmpty(errTags, "pkg", err.topAppFrame.pkg)
-	setTagIfNotEmpty(errTags, "fn", err.topAppFrame.fn)
-	setTagIfNotEmpty(errTags, "line", strconv.Itoa(err.topAppFrame.line))
+	// add originating stack frame tags, if there are any frames, e.g. unmarshaled errors may have none
+	if f := err.topAppFrame; f != nil {
+		setTagIfNotEmpty(errTags, "file", f.file)
+		setTagIfNotEmpty(errTags, "path", f.path)
+		setTagIfNotEmpty(errTags, "pkg", f.pkg)
+		setTagIfNotEmpty(errTags, "fn", f.fn)
+		setTagIfNotEmpty(errTags, "line", strconv.Itoa(f.line))
+	}
 
 	// add additional request fields if available
-	var activeRequest *http.Request
-	// activeRequest := internal.Request(c)
+	activeRequest := err.labels.Request
 	if activeRequest != nil {
 		u := activeRequest.URL.String()
 		// don't include querystring parameters in the requestPath
@@ -178,10 +290,10 @@ func (err *Err) sentryTags(c context.Context) map[string]string {
 		}
 
 		setTagIfNotEmpty(errTags, "requestPath", u[:questionIdx])
-		setTagIfNotEmpty(errTags, "rawQuery", activeRequest.URL.RawQuery)
+		setTagIfNotEmpty(errTags, "rawQuery", err.cl().scrub.query(activeRequest.URL.RawQuery))
 		setTagIfNotEmpty(errTags, "method", activeRequest.Method)
 		setTagIfNotEmpty(errTags, "userAgent", activeRequest.UserAgent())
-		setTagIfNotEmpty(errTags, "referrer", activeRequest.Referer())
+		setTagIfNotEmpty(errTags, "referrer", err.cl().scrub.url(activeRequest.Referer()))
 		setTagIfNotEmpty(errTags, "remoteIP", strings.TrimSuffix(activeRequest.RemoteAddr, ":80"))
 	}
 
@@ -193,7 +305,8 @@ func (err *Err) sentryTags(c context.Context) map[string]string {
 	// 	setTagIfNotEmpty(errTags, "tryCount", strconv.FormatInt(int64(tryCount), 10))
 	// }
 
-	return errTags
+	// user tags, labels and request fields can all carry credentials
+	return err.cl().scrub.tags(errTags)
 }
 
 func setTagIfNotEmpty(m map[string]string, k, v string) {
@@ -226,14 +339,29 @@ func (err *Err) sentryLevel() sentry.Level {
 // sentryStacktrace converts our stack representation to Sentry's. We store it top
 // down, but Sentry expects it bottom first.
 func (err *Err) sentryStacktrace() *sentry.Stacktrace {
+	cl := err.cl()
 	sentryFrames := make([]sentry.Frame, 0, len(err.unknownFrames)+len(err.frames))
 
 	for i := len(err.unknownFrames) - 1; i >= 0; i-- {
-		sentryFrames = append(sentryFrames, frameToSentryFrame(err.unknownFrames[i]))
+		sentryFrames = append(sentryFrames, cl.sentryFrame(err.unknownFrames[i]))
 	}
 
 	for i := len(err.frames) - 1; i >= 0; i-- {
-		sentryFrames = append(sentryFrames, frameToSentryFrame(err.frames[i]))
+		sentryFrames = append(sentryFrames, cl.sentryFrame(err.frames[i]))
+	}
+
+	return &sentry.Stacktrace{
+		Frames: sentryFrames,
+	}
+}
+
+// sentryRemoteStacktrace converts the remote frames to Sentry's representation, bottom first
+func (err *Err) sentryRemoteStacktrace() *sentry.Stacktrace {
+	cl := err.cl()
+	sentryFrames := make([]sentry.Frame, 0, len(err.remoteFrames))
+
+	for i := len(err.remoteFrames) - 1; i >= 0; i-- {
+		sentryFrames = append(sentryFrames, cl.sentryFrame(err.remoteFrames[i]))
 	}
 
 	return &sentry.Stacktrace{
@@ -241,7 +369,9 @@ func (err *Err) sentryStacktrace() *sentry.Stacktrace {
 	}
 }
 
-func frameToSentryFrame(f *frame) sentry.Frame {
+// sentryFrame converts a frame to Sentry's representation, with its vars and message
+// scrubbed. The vars are copied, so adding the message and link doesn't change the frame.
+func (cl *Client) sentryFrame(f *frame) sentry.Frame {
 	sentryFrame := sentry.Frame{
 		Function: f.fn,
 		Package:  f.pkg,
@@ -249,7 +379,7 @@ func frameToSentryFrame(f *frame) sentry.Frame {
 		AbsPath:  f.path,
 		Lineno:   f.line,
 		InApp:    f.class == classApp || f.class == classPkg,
-		Vars:     f.vars,
+		Vars:     cl.scrub.vars(f.vars),
 	}
 
 	// if a custom message has been added to the frame, add it to the vars
@@ -258,7 +388,15 @@ func frameToSentryFrame(f *frame) sentry.Frame {
 		if sentryFrame.Vars == nil {
 			sentryFrame.Vars = make(map[string]interface{})
 		}
-		sentryFrame.Vars["_Msg"] = f.msg
+		sentryFrame.Vars["_Msg"] = cl.scrub.str(f.msg)
+	}
+
+	// Sentry frames don't have a url field, so the source link is added to the vars
+	if link := cl.link(f); link != "" {
+		if sentryFrame.Vars == nil {
+			sentryFrame.Vars = make(map[string]interface{})
+		}
+		sentryFrame.Vars["_Link"] = link
 	}
 
 	return sentryFrame
@@ -268,30 +406,31 @@ func frameToSentryFrame(f *frame) sentry.Frame {
 func (err *Err) sentryExtra() map[string]interface{} {
 	m := map[string]interface{}{
 		"stackDepth": len(err.frames),
+	}
 
-		"MemStats.General.Alloc":       err.memStats.Alloc,
-		"MemStats.General.TotalAlloc":  err.memStats.TotalAlloc,
-		"MemStats.General.Sys":         err.memStats.Sys,
-		"MemStats.General.Mallocs":     err.memStats.Mallocs,
-		"MemStats.General.Frees":       err.memStats.Frees,
-		"MemStats.General.LiveObjects": err.memStats.Mallocs - err.memStats.Frees,
-
-		"MemStats.Heap.Alloc":    err.memStats.HeapAlloc,
-		"MemStats.Heap.Sys":      err.memStats.HeapSys,
-		"MemStats.Heap.Idle":     err.memStats.HeapIdle,
-		"MemStats.Heap.Inuse":    err.memStats.HeapInuse,
-		"MemStats.Heap.Released": err.memStats.HeapReleased,
-		"MemStats.Heap.Objects":  err.memStats.HeapObjects,
-
-		"MemStats.GC.NextGC":       err.memStats.NextGC,
-		"MemStats.GC.LastGC":       err.memStats.LastGC,
-		"MemStats.GC.PauseTotalNs": err.memStats.PauseTotalNs,
-		"MemStats.GC.NumGC":        err.memStats.NumGC,
-		"MemStats.GC.CPUFraction":  fmt.Sprintf("%.3f%%", err.memStats.GCCPUFraction*100),
-	}
-
-	for _, ev := range envVars {
-		m["env."+ev.k] = ev.v
+	if err.suppressed > 0 {
+		m["suppressed"] = strconv.Itoa(err.suppressed) + " similar events suppressed"
+	}
+
+	// runtime statistics may be turned off, and errors unmarshaled from JSON don't have them
+	if err.runtimeStats != nil {
+		err.runtimeStats.addExtra(m)
+	}
+
+	cl := err.cl()
+	if details := err.Details(); len(details) > 0 {
+		rendered := make([]string, 0, len(details))
+		for _, detail := range details {
+			rendered = append(rendered, cl.renderDetail(detail))
+		}
+		m["errDetails"] = rendered
+	}
+
+	// environment variables are only shipped if they're allowlisted, and even then scrubbed
+	for _, ev := range cl.envVars {
+		if cl.scrub.allowEnv(ev.k) {
+			m["env."+ev.k] = cl.scrub.str(ev.v)
+		}
 	}
 
 	return m
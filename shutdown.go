@@ -0,0 +1,74 @@
+package e
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FlushOnShutdown flushes the default client when the process receives SIGTERM or
+// SIGINT, see (*Client).FlushOnShutdown
+func FlushOnShutdown(timeout time.Duration) (stop func()) {
+	return DefaultClient().FlushOnShutdown(timeout)
+}
+
+// FlushOnShutdown flushes the client for up to timeout when the process receives
+// SIGTERM or SIGINT, e.g. when Kubernetes stops a pod, and then raises the signal
+// again with its default handling restored, so the process exits as it would have,
+// or keeps running if the signal is ignored or handled elsewhere. Calling stop
+// uninstalls the handler.
+//
+// The exit status only changes if the signal can't be raised again, e.g. on Windows,
+// in which case the process exits with status 1 instead of the one the signal gives.
+//
+// This is meant for apps that don't handle the signals themselves. Apps with their
+// own graceful shutdown should call Flush at the end of it instead.
+func (cl *Client) FlushOnShutdown(timeout time.Duration) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			c, cancel := context.WithTimeout(context.Background(), timeout)
+			if flushErr := cl.Flush(c); flushErr != nil {
+				log.Println("flush on " + sig.String() + " did NOT complete: " + flushErr.Error())
+			}
+			cancel()
+
+			signal.Stop(sigs)
+			reraise(sig)
+
+		case <-done:
+			signal.Stop(sigs)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// reraise sends the signal to the process again now that it isn't caught, leaving
+// what happens to its disposition, and falling back to exiting when the platform
+// can't deliver it. It's replaced in tests.
+var reraise = func(sig os.Signal) {
+	p, findErr := os.FindProcess(os.Getpid())
+	if findErr == nil {
+		sigErr := p.Signal(sig)
+		if sigErr == nil {
+			return
+		}
+		log.Println("unable to raise " + sig.String() + " again: " + sigErr.Error())
+	}
+
+	os.Exit(1)
+}
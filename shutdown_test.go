@@ -0,0 +1,104 @@
+package e
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hangingReporter never finishes flushing before the context is done
+type hangingReporter struct {
+	testReporter
+}
+
+func (hr *hangingReporter) Flush(c context.Context) error {
+	<-c.Done()
+	return nil
+}
+
+func TestFlushDeadline(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(WithReporter("hanging", &hangingReporter{}), WithReporter("ok", tr))
+
+	c, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	flushErr := cl.Flush(c)
+	if assert.Error(t, flushErr) {
+		assert.Equal(t, "reporters did not drain: hanging (context deadline exceeded)", flushErr.Error())
+	}
+	assert.Equal(t, 1, tr.flushed)
+
+	// a queue that can't drain is reported before the reporters are flushed
+	br := &blockingReporter{started: make(chan struct{}, 10), release: make(chan struct{})}
+	cl = NewClient(WithReporter("blocking", br))
+	New("some error").Report(context.Background(), ReportWithClient(cl))
+	<-br.started
+
+	c, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	flushErr = cl.Flush(c)
+	if assert.Error(t, flushErr) {
+		assert.Equal(t, "report queue did not drain: context deadline exceeded", flushErr.Error())
+	}
+	close(br.release)
+}
+
+func TestFlushOnShutdown(t *testing.T) {
+	prev := reraise
+	defer func() { reraise = prev }()
+
+	reraised := make(chan os.Signal, 1)
+	reraise = func(sig os.Signal) {
+		reraised <- sig
+	}
+
+	tr := &testReporter{}
+	cl := NewClient(WithReporter("r", tr))
+	stop := cl.FlushOnShutdown(time.Second)
+	defer stop()
+
+	New("some error").Report(context.Background(), ReportWithClient(cl))
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Skip("can't find own process: " + err.Error())
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skip("can't signal own process: " + err.Error())
+	}
+
+	select {
+	case sig := <-reraised:
+		assert.Equal(t, os.Interrupt, sig)
+		assert.Len(t, tr.reported, 1)
+		assert.Equal(t, 1, tr.flushed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the signal wasn't handled")
+	}
+}
+
+func TestReraiseHandledElsewhere(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent to the process on windows")
+	}
+
+	// another subscriber keeps the process running, so reraise has to return
+	other := make(chan os.Signal, 1)
+	signal.Notify(other, syscall.SIGTERM)
+	defer signal.Stop(other)
+
+	reraise(syscall.SIGTERM)
+	select {
+	case sig := <-other:
+		assert.Equal(t, syscall.SIGTERM, sig)
+	case <-time.After(time.Second):
+		t.Fatal("the signal wasn't raised again")
+	}
+}
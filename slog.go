@@ -0,0 +1,210 @@
+package e
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+var _ slog.LogValuer = (*Err)(nil)
+
+// LogValue fulfills the slog.LogValuer interface, so that errors are logged as
+// structured groups instead of the full report returned by Error():
+//
+//	msg        the root error message prefixed by the frame messages, like %v
+//	code       the gRPC code
+//	level      critical, error or warning
+//	retriable  whether a retry might succeed
+//	infra      only set if the error is infrastructure related
+//	panic      only set if the error was recovered from a panic
+//	tags       the tags set with the Tag WrapOption
+//	frame      fn, file, line and link of the top app frame
+//	vars       the vars of every frame, where outer wraps override inner ones
+//	joined     the errors joined into this one, keyed by index
+//
+// Tags, vars and messages are scrubbed, see SetScrubRules.
+func (err *Err) LogValue() slog.Value {
+	err.resolveFrames()
+
+	cl := err.cl()
+	attrs := []slog.Attr{
+		slog.String("msg", err.shortString()),
+		slog.String("code", err.code.String()),
+		slog.String("level", err.Level.String()),
+		slog.Bool("retriable", err.isRetriable),
+	}
+
+	if err.isInfra {
+		attrs = append(attrs, slog.Bool("infra", true))
+	}
+
+	if err.isPanic {
+		attrs = append(attrs, slog.Bool("panic", true))
+	}
+
+	if len(err.tags) > 0 {
+		tagAttrs := make([]slog.Attr, 0, len(err.tags))
+		for k, v := range cl.scrub.tags(err.tags) {
+			tagAttrs = append(tagAttrs, slog.String(k, v))
+		}
+		attrs = append(attrs, groupAttr("tags", tagAttrs))
+	}
+
+	if f := err.topAppFrame; f != nil {
+		frameAttrs := []slog.Attr{
+			slog.String("fn", f.pkg+"."+f.fn),
+			slog.String("file", f.path+"/"+f.file),
+			slog.Int("line", f.line),
+		}
+		if link := cl.link(f); link != "" {
+			frameAttrs = append(frameAttrs, slog.String("link", link))
+		}
+		attrs = append(attrs, slog.Attr{Key: "frame", Value: slog.GroupValue(frameAttrs...)})
+	}
+
+	// frames are stored top down, so iterating them in order lets outer wraps win
+	vars := map[string]interface{}{}
+	for _, fs := range []stackframes{err.frames, err.unknownFrames} {
+		for _, f := range fs {
+			for k, v := range f.vars {
+				vars[k] = v
+			}
+		}
+	}
+	if len(vars) > 0 {
+		varAttrs := make([]slog.Attr, 0, len(vars))
+		for k, v := range cl.scrub.vars(vars) {
+			varAttrs = append(varAttrs, slog.Any(k, v))
+		}
+		attrs = append(attrs, groupAttr("vars", varAttrs))
+	}
+
+	if len(err.children) > 0 {
+		childAttrs := make([]slog.Attr, 0, len(err.children))
+		for i, child := range err.children {
+			childAttrs = append(childAttrs, slog.Any(strconv.Itoa(i), child))
+		}
+		attrs = append(attrs, slog.Attr{Key: "joined", Value: slog.GroupValue(childAttrs...)})
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// groupAttr returns a group of the attrs sorted by key, since they come from maps
+func groupAttr(key string, attrs []slog.Attr) slog.Attr {
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+
+	return slog.Attr{Key: key, Value: slog.GroupValue(attrs...)}
+}
+
+// SlogHandler wraps a slog.Handler and reports every Err found in the attributes of
+// records at or above its report level, before passing the records on. Errors are
+// only reported once, even if they're logged again or were already reported.
+type SlogHandler struct {
+	next        slog.Handler
+	reportLevel slog.Leveler
+	reportOpts  []ReportOption
+
+	// attributes added with WithAttrs, which are scanned for errors as well
+	attrs []slog.Attr
+}
+
+// A SlogHandlerOption configures a SlogHandler
+type SlogHandlerOption func(h *SlogHandler)
+
+// SlogReportLevel sets the minimum level of records whose errors are reported,
+// which defaults to slog.LevelError
+func SlogReportLevel(level slog.Leveler) SlogHandlerOption {
+	return func(h *SlogHandler) {
+		h.reportLevel = level
+	}
+}
+
+// SlogReportOptions sets the options errors are reported with, e.g. ReportWithClient
+func SlogReportOptions(opts ...ReportOption) SlogHandlerOption {
+	return func(h *SlogHandler) {
+		h.reportOpts = opts
+	}
+}
+
+// NewSlogHandler returns a handler that reports errors before passing records to next
+func NewSlogHandler(next slog.Handler, opts ...SlogHandlerOption) *SlogHandler {
+	h := &SlogHandler{
+		next:        next,
+		reportLevel: slog.LevelError,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Enabled fulfills the slog.Handler interface
+func (h *SlogHandler) Enabled(c context.Context, level slog.Level) bool {
+	return h.next.Enabled(c, level)
+}
+
+// Handle fulfills the slog.Handler interface
+func (h *SlogHandler) Handle(c context.Context, r slog.Record) error {
+	if r.Level >= h.reportLevel.Level() {
+		for _, attr := range h.attrs {
+			h.reportAttr(c, attr)
+		}
+
+		r.Attrs(func(attr slog.Attr) bool {
+			h.reportAttr(c, attr)
+			return true
+		})
+	}
+
+	return h.next.Handle(c, r)
+}
+
+// WithAttrs fulfills the slog.Handler interface
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithAttrs(attrs)
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+
+	return &h2
+}
+
+// WithGroup fulfills the slog.Handler interface
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+
+	return &h2
+}
+
+// reportAttr reports the error in attr, or in any attr of a group
+func (h *SlogHandler) reportAttr(c context.Context, attr slog.Attr) {
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		for _, groupAttr := range attr.Value.Group() {
+			h.reportAttr(c, groupAttr)
+		}
+
+	case slog.KindAny, slog.KindLogValuer:
+		rawErr, ok := attr.Value.Any().(error)
+		if !ok {
+			return
+		}
+
+		err, ok := asErr(rawErr)
+		if ok && err.markReported() {
+			err.Report(c, h.reportOpts...)
+		}
+	}
+}
+
+// markReported flags the error as reported, returning false if it already was
+func (err *Err) markReported() bool {
+	return atomic.CompareAndSwapInt32(&err.reported, 0, 1)
+}
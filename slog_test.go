@@ -0,0 +1,83 @@
+package e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json" //nolint:depguard // there are import cycles with pkg/json
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestLogValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	err := New("some error", Code(codes.NotFound), Msg("some message"), Tag("b", "2"), Tag("a", "1"), With("k", "v"), Infra())
+	logger.Info("failed", "err", err)
+
+	var got map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+
+	errAttrs, ok := got["err"].(map[string]interface{})
+	if !assert.True(t, ok) {
+		return
+	}
+
+	frame, _ := errAttrs["frame"].(map[string]interface{})
+	assert.Equal(t, "e.TestLogValue", frame["fn"])
+	assert.Equal(t, "github.com/nozzle/e/slog_test.go", frame["file"])
+	delete(errAttrs, "frame")
+
+	assert.Equal(t, map[string]interface{}{
+		"msg":       "some message: some error",
+		"code":      "NotFound",
+		"level":     "error",
+		"retriable": true,
+		"infra":     true,
+		"tags":      map[string]interface{}{"a": "1", "b": "2"},
+		"vars":      map[string]interface{}{"k": "v"},
+	}, errAttrs)
+}
+
+func TestSlogHandler(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(WithReporter("slog", tr))
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(NewSlogHandler(slog.NewTextHandler(buf, nil), SlogReportOptions(ReportWithClient(cl))))
+	c := context.Background()
+
+	// below the report level
+	warnErr := New("warning")
+	logger.WarnContext(c, "failed", "err", warnErr)
+	drain(cl)
+	assert.Len(t, tr.reported, 0)
+
+	// errors are found wrapped and in groups, but only reported once
+	err := New("some error")
+	logger.ErrorContext(c, "failed", "err", fmt.Errorf("context: %w", err))
+	logger.ErrorContext(c, "failed again", slog.Group("request", "err", err))
+	drain(cl)
+	if assert.Len(t, tr.reported, 1) {
+		assert.Equal(t, err.shortString(), tr.reported[0].shortString())
+	}
+
+	// attributes added with With are scanned too
+	withErr := New("with error")
+	logger.With("err", withErr).ErrorContext(c, "failed")
+	drain(cl)
+	assert.Len(t, tr.reported, 2)
+
+	// errors reported before they're logged aren't reported again
+	reportedErr := New("reported error")
+	reportedErr.Report(c, ReportWithClient(cl))
+	logger.ErrorContext(c, "failed", "err", reportedErr)
+	drain(cl)
+	assert.Len(t, tr.reported, 3)
+
+	assert.Contains(t, buf.String(), "err.msg=\"some error\"")
+}
@@ -3,17 +3,36 @@ package e
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"path"
+	"runtime"
 	"strings"
 
 	"github.com/maruel/panicparse/v2/stack"
 )
 
+// maxStackDepth is the maximum number of program counters recorded when an error is created
+const maxStackDepth = 64
+
+// callers records the program counters of the calling goroutine, where skip is
+// the number of frames to skip above the caller of callers. This is the only
+// work done to capture a stack, they are resolved into frames lazily.
+func callers(skip int) []uintptr {
+	var pcs [maxStackDepth]uintptr
+	// skip runtime.Callers and callers itself
+	n := runtime.Callers(skip+2, pcs[:])
+
+	return append(make([]uintptr, 0, n), pcs[:n]...)
+}
+
 // findFrame loops through the existing stack frames to find the one that matches the function name.
 // We can't compare line numbers because the stacktrace reports the line for the function,
 // while the Wrap call reports the source line of the Wrap. This is a coarse find
 func (err *Err) findFrame(fullFnName string) int {
+	err.resolveFrames()
+
 	var f *frame
 	for i := err.currentFrameIdx; i < len(err.frames); i++ {
 		f = err.frames[i]
@@ -25,31 +44,159 @@ func (err *Err) findFrame(fullFnName string) int {
 	return -1
 }
 
-func parseStack(rawStack []byte) []*frame {
+// resolveFrames turns the captured stack into frames the first time frames are needed.
+// The stack comes from the Stack option if it was set on the initial wrap, otherwise
+// from the recorded program counters.
+func (err *Err) resolveFrames() {
+	err.framesMu.Lock()
+	defer err.framesMu.Unlock()
+
+	if err.framesResolved {
+		return
+	}
+	err.framesResolved = true
+
+	switch {
+	case err.rawStack != nil:
+		err.frames = err.cl().parseStack(err.rawStack)
+
+	case len(err.pcs) > 0:
+		err.frames = err.cl().parseCallers(err.pcs)
+	}
+
+	// frame scoped options from the initial wrap belong to the top frame
+	if pf := err.pendingFrame; pf != nil {
+		err.pendingFrame = nil
+
+		if len(err.frames) == 0 {
+			err.frames = stackframes{pf}
+		} else {
+			err.frames[0].msg = pf.msg
+			err.frames[0].vars = pf.vars
+			err.frames[0].errDetails = pf.errDetails
+		}
+	}
+
+	// the SkipFrames option can only be applied now that the stack has been parsed
+	if err.skipFrames > 0 {
+		err.handleSkipFrameOption()
+	}
+
+	err.setTopAppFrame()
+
+	for _, child := range err.children {
+		child.resolveFrames()
+	}
+}
+
+// parseCallers resolves program counters from runtime.Callers into frames
+func (cl *Client) parseCallers(pcs []uintptr) []*frame {
+	callFrames := runtime.CallersFrames(pcs)
+	frames := make([]*frame, 0, len(pcs))
+
+	for more := true; more; {
+		var call runtime.Frame
+		call, more = callFrames.Next()
+
+		importPath, fn := splitFuncName(call.Function)
+
+		// hide the same runtime internals as debug.Stack, e.g. runtime.goexit
+		if importPath == "runtime" && !strings.Contains(fn, "anic") {
+			continue
+		}
+
+		// determine whether or not to skip frames that just clutter the stacktrace
+		if cl.shouldSkipFrame(importPath, fn, call.Function, len(frames)) {
+			continue
+		}
+
+		// once we reach the router, grpc layer, or root worker, we can eliminate the rest of the stack
+		if cl.shouldTruncateStack(importPath, fn, call.Function) && len(frames) > 1 {
+			break
+		}
+
+		frames = append(frames, &frame{
+			file:    path.Base(call.File),
+			absPath: call.File,
+			path:    importPath,
+			pkg:     path.Base(importPath),
+			fn:      fn,
+			full:    call.Function,
+			line:    call.Line,
+			class:   cl.getCallerClass(importPath, call.Function),
+		})
+	}
+
+	return frames
+}
+
+// splitFuncName splits a fully qualified function name from the runtime, such as
+// github.com/nozzle/e.(*Err).Error, into its import path and function name
+func splitFuncName(full string) (string, string) {
+	lastSlash := strings.LastIndexByte(full, '/')
+	dot := strings.IndexByte(full[lastSlash+1:], '.')
+	if dot == -1 {
+		return "", full
+	}
+	dot += lastSlash + 1
+
+	// dots in the last path element are escaped by the runtime, e.g. gopkg.in/yaml%2ev3
+	return strings.ReplaceAll(full[:dot], "%2e", "."), full[dot+1:]
+}
+
+// formatCallers renders program counters in the same format as debug.Stack, which
+// is what Google Error Reporting expects
+func formatCallers(pcs []uintptr) []byte {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	buf.WriteString("goroutine 1 [running]:\n")
+
+	callFrames := runtime.CallersFrames(pcs)
+	for more := true; more; {
+		var call runtime.Frame
+		call, more = callFrames.Next()
+		fmt.Fprintf(buf, "%s(...)\n\t%s:%d +0x%x\n", call.Function, call.File, call.Line, call.PC-call.Entry)
+	}
+
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+// stackTrace returns the stack in the format of debug.Stack
+func (err *Err) stackTrace() []byte {
+	if err.rawStack != nil {
+		return err.rawStack
+	}
+
+	return formatCallers(err.pcs)
+}
+
+func (cl *Client) parseStack(rawStack []byte) []*frame {
 	ppStack := getPanicParseStack(rawStack)
 	frames := make([]*frame, 0, len(ppStack.Calls))
 
 	for _, call := range ppStack.Calls {
 		// determine whether or not to skip frames that just clutter the stacktrace
-		if shouldSkipFrame(&call, len(frames)) {
+		if cl.shouldSkipFrame(call.Func.ImportPath, call.Func.Name, call.Func.Complete, len(frames)) {
 			continue
 		}
 
 		// once we reach the router, grpc layer, or root worker, we can eliminate the rest of the stack
 		// checking the length of the frames prevents false positives, in case the error originates
 		// in a frame that typically causes truncation. As of 2021-02-24, this only happens in testing.
-		if shouldTruncateStack(&call) && len(frames) > 1 {
+		if cl.shouldTruncateStack(call.Func.ImportPath, call.Func.Name, call.Func.Complete) && len(frames) > 1 {
 			break
 		}
 
 		frames = append(frames, &frame{
-			file:  call.SrcName,
-			path:  call.Func.ImportPath,
-			pkg:   call.Func.DirName,
-			fn:    call.Func.Name,
-			full:  call.Func.Complete,
-			line:  call.Line,
-			class: getFrameClass(&call),
+			file:    call.SrcName,
+			absPath: call.RemoteSrcPath,
+			path:    call.Func.ImportPath,
+			pkg:     call.Func.DirName,
+			fn:      call.Func.Name,
+			full:    call.Func.Complete,
+			line:    call.Line,
+			class:   cl.getFrameClass(&call),
 		})
 	}
 
@@ -71,7 +218,7 @@ func getPanicParseStack(rawStack []byte) stack.Stack {
 	return s.Goroutines[0].Signature.Stack
 }
 
-func getFrameClass(call *stack.Call) class {
+func (cl *Client) getFrameClass(call *stack.Call) class {
 	// set the frame type
 	switch {
 	case call.Func.Name == "panic":
@@ -80,15 +227,15 @@ func getFrameClass(call *stack.Call) class {
 	case call.Location == stack.Stdlib:
 		return classStdLib
 
-	case strings.HasPrefix(call.Func.ImportPath, "go.nozzle.io/pkg"):
-		return classPkg
-
-	case strings.HasPrefix(call.Func.ImportPath, "go.nozzle.io/vendor"):
-		return classVendor
-
-	case strings.HasPrefix(call.Func.ImportPath, "go.nozzle.io"):
+	case call.Func.IsPkgMain:
 		return classApp
+	}
 
+	if c, ok := cl.classRules.classify(call.Func.ImportPath); ok {
+		return c
+	}
+
+	switch {
 	case call.Location == stack.GoPkg || call.Location == stack.GoMod:
 		return classVendor
 
@@ -100,55 +247,43 @@ func getFrameClass(call *stack.Call) class {
 	}
 }
 
-//nolint:gocyclo
-func shouldSkipFrame(call *stack.Call, frameCount int) bool {
-	// for now, we're only skipping frames at the top of the stack
-	if frameCount > 0 {
-		return false
-	}
-
-	// skip the wrap functions in this package if they are at the top of the stack
-	if call.Func.DirName == "e" &&
-		(call.Func.Name == "wrap" || call.Func.Name == "Wrap" || call.Func.Name == "New" || call.Func.Name == "StatusToError") {
-		return true
-	}
+// getCallerClass is getFrameClass for frames resolved from program counters, which
+// don't have panicparse's knowledge of GOROOT and GOPATH
+func (cl *Client) getCallerClass(importPath, full string) class {
+	switch {
+	case full == "runtime.gopanic":
+		return classPanic
 
-	// skip the runtime debug frame
-	if call.Func.ImportPath == "runtime/debug" && call.Func.Name == "Stack" {
-		return true
+	case importPath == "main":
+		return classApp
 	}
 
-	// skip extra client frames for grpc calls
-	if call.Func.DirName == "ngrpc" && call.Func.Name == "contextClientUnaryInterceptor" {
-		return true
+	if c, ok := cl.classRules.classify(importPath); ok {
+		return c
 	}
 
-	if call.Func.DirName == "grpc" && call.Func.Name == "(*ClientConn).Invoke" {
-		return true
+	// the first element of stdlib import paths never contains a dot
+	firstElem := importPath
+	if slash := strings.IndexByte(importPath, '/'); slash != -1 {
+		firstElem = importPath[:slash]
 	}
 
-	// skip MySQL error normalization
-	if call.Func.DirName == "mysql" &&
-		(call.Func.Name == "normalizeError" ||
-			call.Func.Name == "(*DB).ExecContext" || call.Func.Name == "(*DB).QueryContext" || call.Func.Name == "(*DB).QueryRowContext" ||
-			call.Func.Name == "(*Tx).ExecContext" || call.Func.Name == "(*Tx).QueryContext" || call.Func.Name == "(*Tx).QueryRowContext" ||
-			call.Func.Name == "(*Stmt).ExecContext" || call.Func.Name == "(*Stmt).QueryContext" || call.Func.Name == "(*Stmt).QueryRowContext") {
-		return true
+	if !strings.Contains(firstElem, ".") {
+		return classStdLib
 	}
 
-	return false
+	return classVendor
 }
 
-func shouldTruncateStack(call *stack.Call) bool {
-	switch call.Func.Complete {
-	case "go.nozzle.io/pkg/router.route.func1",
-		"go.nozzle.io/pkg/ngrpc.execHandler",
-		"go.nozzle.io/pkg/workers.(*worker).execUserFn",
-		"github.com/spf13/cobra.(*Command).execute",
-		"testing.tRunner":
-		return true
-
-	default:
+func (cl *Client) shouldSkipFrame(importPath, fn, full string, frameCount int) bool {
+	// for now, we're only skipping frames at the top of the stack
+	if frameCount > 0 {
 		return false
 	}
+
+	return cl.stackRules.matches(SkipAtTop, importPath, fn, full)
+}
+
+func (cl *Client) shouldTruncateStack(importPath, fn, full string) bool {
+	return cl.stackRules.matches(TruncateBelow, importPath, fn, full)
 }
@@ -0,0 +1,216 @@
+package e
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// StackAction determines what happens to a stack when a StackRule matches one of its frames
+type StackAction int
+
+const (
+	// SkipAtTop removes the matching frame while it's at the top of the stack.
+	// This is meant for helpers that create or normalize errors on behalf of their caller.
+	SkipAtTop StackAction = iota + 1
+	// TruncateBelow removes the matching frame and every frame below it. This is meant
+	// for framework code that calls into the app, e.g. routers, RPC servers and CLI runners.
+	// Frames are never truncated until at least two have been kept.
+	TruncateBelow
+)
+
+// StackRule matches stack frames by import path, function name, glob or regular
+// expression. Every field that is set must match, and a rule without any
+// criteria never matches.
+type StackRule struct {
+	// ImportPath matches the package import path exactly, e.g. "google.golang.org/grpc"
+	ImportPath string
+	// Func matches the function name within the package exactly, e.g. "(*ClientConn).Invoke"
+	Func string
+	// Pattern is a glob matched against the fully qualified function name, where
+	// '*' matches any run of characters and '?' matches a single character,
+	// e.g. "github.com/acme/app/router.*"
+	Pattern string
+	// Regexp is matched against the fully qualified function name
+	Regexp *regexp.Regexp
+	// Action is what happens to the stack when the rule matches
+	Action StackAction
+}
+
+// AddStackRules adds rules to the default client that skip or truncate stack frames.
+// Stacks are parsed lazily, so this should be called at app startup.
+func AddStackRules(rules ...StackRule) {
+	DefaultClient().AddStackRules(rules...)
+}
+
+// SetStackRules replaces all rules previously added to the default client, including
+// the defaults. The frames of this package's own wrapping functions are always skipped.
+func SetStackRules(rules ...StackRule) {
+	DefaultClient().SetStackRules(rules...)
+}
+
+// DefaultStackRules returns the rules that are used when SetStackRules hasn't been
+// called: testing.tRunner truncation, plus the gRPC and cobra rule packs.
+func DefaultStackRules() []StackRule {
+	rules := []StackRule{
+		{ImportPath: "testing", Func: "tRunner", Action: TruncateBelow},
+	}
+	rules = append(rules, GRPCStackRules()...)
+	rules = append(rules, CobraStackRules()...)
+
+	return rules
+}
+
+// NetHTTPStackRules truncates net/http server internals below the app's handlers
+func NetHTTPStackRules() []StackRule {
+	return []StackRule{
+		{ImportPath: "net/http", Func: "serverHandler.ServeHTTP", Action: TruncateBelow},
+		{ImportPath: "net/http", Func: "(*ServeMux).ServeHTTP", Action: TruncateBelow},
+	}
+}
+
+// GRPCStackRules skips grpc-go client invocation frames and truncates server
+// internals below the generated service handlers
+func GRPCStackRules() []StackRule {
+	return []StackRule{
+		{ImportPath: "google.golang.org/grpc", Func: "(*ClientConn).Invoke", Action: SkipAtTop},
+		{ImportPath: "google.golang.org/grpc", Func: "Invoke", Action: SkipAtTop},
+		{ImportPath: "google.golang.org/grpc", Func: "invoke", Action: SkipAtTop},
+		{ImportPath: "google.golang.org/grpc", Func: "chainUnaryClientInterceptors.func1", Action: SkipAtTop},
+		{ImportPath: "google.golang.org/grpc", Func: "getChainUnaryInvoker.func1", Action: SkipAtTop},
+		{ImportPath: "google.golang.org/grpc", Func: "(*ClientConn).NewStream", Action: SkipAtTop},
+		{ImportPath: "google.golang.org/grpc", Func: "chainStreamClientInterceptors.func1", Action: SkipAtTop},
+		{ImportPath: "google.golang.org/grpc", Func: "getChainStreamer.func1", Action: SkipAtTop},
+		{Regexp: regexp.MustCompile(`\._\w+_\w+_Handler(\.func\d+)?$`), Action: TruncateBelow},
+		{ImportPath: "google.golang.org/grpc", Func: "(*Server).processUnaryRPC", Action: TruncateBelow},
+		{ImportPath: "google.golang.org/grpc", Func: "(*Server).processStreamingRPC", Action: TruncateBelow},
+	}
+}
+
+// CobraStackRules truncates cobra's command execution below the app's commands
+func CobraStackRules() []StackRule {
+	return []StackRule{
+		{ImportPath: "github.com/spf13/cobra", Func: "(*Command).execute", Action: TruncateBelow},
+	}
+}
+
+// DatabaseSQLStackRules skips database/sql frames at the top of the stack, e.g. when
+// errors are created in Scanner or Valuer implementations
+func DatabaseSQLStackRules() []StackRule {
+	return []StackRule{
+		{ImportPath: "database/sql", Action: SkipAtTop},
+		{ImportPath: "database/sql/driver", Action: SkipAtTop},
+	}
+}
+
+// pkgPath is the import path of this package, used to skip its own frames
+var pkgPath = reflect.TypeOf(Err{}).PkgPath()
+
+// internalStackRules can't be removed, since they only strip this package's own frames
+var internalStackRules = []StackRule{
+	{ImportPath: pkgPath, Func: "wrap", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "Wrap", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "New", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "StatusToError", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "statusToError", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "(*serverInterceptor).handleErr", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "UnaryServerInterceptor.func1", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "StreamServerInterceptor.func1", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "(*clientInterceptor).convert", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "UnaryClientInterceptor.func1", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "StreamClientInterceptor.func1", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "(*grpcClientStream).convert", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "(*grpcClientStream).SendMsg", Action: SkipAtTop},
+	{ImportPath: pkgPath, Func: "(*grpcClientStream).RecvMsg", Action: SkipAtTop},
+	{ImportPath: "runtime/debug", Func: "Stack", Action: SkipAtTop},
+}
+
+type compiledStackRule struct {
+	StackRule
+	pattern *regexp.Regexp
+}
+
+type stackRuleSet struct {
+	mu    sync.RWMutex
+	rules []compiledStackRule
+}
+
+func newStackRuleSet(rules []StackRule) *stackRuleSet {
+	srs := &stackRuleSet{}
+	srs.set(rules...)
+	return srs
+}
+
+func (srs *stackRuleSet) add(rules ...StackRule) {
+	compiled := compileStackRules(rules)
+
+	srs.mu.Lock()
+	defer srs.mu.Unlock()
+
+	srs.rules = append(srs.rules[:len(srs.rules):len(srs.rules)], compiled...)
+}
+
+func (srs *stackRuleSet) set(rules ...StackRule) {
+	compiled := compileStackRules(append(internalStackRules[:len(internalStackRules):len(internalStackRules)], rules...))
+
+	srs.mu.Lock()
+	defer srs.mu.Unlock()
+
+	srs.rules = compiled
+}
+
+// matches returns whether any rule with the given action matches the frame
+func (srs *stackRuleSet) matches(action StackAction, importPath, fn, full string) bool {
+	srs.mu.RLock()
+	defer srs.mu.RUnlock()
+
+	for i := range srs.rules {
+		if srs.rules[i].Action == action && srs.rules[i].matches(importPath, fn, full) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func compileStackRules(rules []StackRule) []compiledStackRule {
+	compiled := make([]compiledStackRule, 0, len(rules))
+	for _, rule := range rules {
+		csr := compiledStackRule{StackRule: rule}
+		if rule.Pattern != "" {
+			csr.pattern = globToRegexp(rule.Pattern)
+		}
+		compiled = append(compiled, csr)
+	}
+
+	return compiled
+}
+
+func (csr *compiledStackRule) matches(importPath, fn, full string) bool {
+	// a rule without any criteria would match every frame
+	if csr.ImportPath == "" && csr.Func == "" && csr.pattern == nil && csr.Regexp == nil {
+		return false
+	}
+
+	switch {
+	case csr.ImportPath != "" && csr.ImportPath != importPath,
+		csr.Func != "" && csr.Func != fn,
+		csr.pattern != nil && !csr.pattern.MatchString(full),
+		csr.Regexp != nil && !csr.Regexp.MatchString(full):
+		return false
+
+	default:
+		return true
+	}
+}
+
+// globToRegexp converts a glob where '*' matches any run of characters and '?'
+// matches a single character into an anchored regular expression
+func globToRegexp(glob string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(glob)
+	quoted = strings.ReplaceAll(quoted, `\*`, `.*`)
+	quoted = strings.ReplaceAll(quoted, `\?`, `.`)
+
+	return regexp.MustCompile("^" + quoted + "$")
+}
@@ -0,0 +1,97 @@
+package e
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackRuleMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       StackRule
+		importPath string
+		fn         string
+		want       bool
+	}{
+		{
+			"import path and func",
+			StackRule{ImportPath: "google.golang.org/grpc", Func: "(*ClientConn).Invoke"},
+			"google.golang.org/grpc", "(*ClientConn).Invoke",
+			true,
+		},
+		{
+			"import path and wrong func",
+			StackRule{ImportPath: "google.golang.org/grpc", Func: "(*ClientConn).Invoke"},
+			"google.golang.org/grpc", "invoke",
+			false,
+		},
+		{
+			"import path only",
+			StackRule{ImportPath: "database/sql"},
+			"database/sql", "convertAssign",
+			true,
+		},
+		{
+			"glob",
+			StackRule{Pattern: "github.com/acme/*/router.route.func?"},
+			"github.com/acme/app/router", "route.func1",
+			true,
+		},
+		{
+			"glob is anchored",
+			StackRule{Pattern: "router.route.*"},
+			"github.com/acme/app/router", "route.func1",
+			false,
+		},
+		{
+			"regexp",
+			StackRule{Regexp: regexp.MustCompile(`\._\w+_\w+_Handler(\.func\d+)?$`)},
+			"github.com/acme/app/pb", "_Greeter_SayHello_Handler.func1",
+			true,
+		},
+		{
+			"no criteria",
+			StackRule{},
+			"github.com/acme/app", "main",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := compileStackRules([]StackRule{tt.rule})[0]
+			assert.Equal(t, tt.want, csr.matches(tt.importPath, tt.fn, tt.importPath+"."+tt.fn))
+		})
+	}
+}
+
+func TestStackRulesParseStack(t *testing.T) {
+	rawStack := []byte(`goroutine 54 [running]:
+github.com/nozzle/e.New(0x1422ac0, 0xc42008c5a0, 0xc42000e060, 0x1, 0x1, 0x39)
+	/Users/derek/go/src/github.com/nozzle/e/wrap.go:19 +0x53
+database/sql.convertAssign(0x1422ac0, 0xc42008c5a0)
+	/usr/local/go/src/database/sql/convert.go:219 +0x53
+database/sql.(*Rows).Scan(0x1422ac0, 0xc42008c5a0)
+	/usr/local/go/src/database/sql/sql.go:3287 +0x53
+go.nozzle.io/views/billingvw.load(0xc4201fc2d0)
+	/Users/derek/go/src/go.nozzle.io/views/billingvw/statements.go:75 +0xd3
+go.nozzle.io/views/billingvw.Load(0xc4201fc2d0)
+	/Users/derek/go/src/go.nozzle.io/views/billingvw/statements.go:64 +0x332
+go.nozzle.io/pkg/router.route.func1(0xde5a80, 0xc4202fb200, 0xc420153600, 0x0)
+	/Users/derek/go/src/go.nozzle.io/pkg/router/router.go:122 +0x914
+net/http.HandlerFunc.ServeHTTP(0x1016118, 0x15208c0, 0xc4210ac000, 0xc4229a0e00)
+	/usr/local/go/src/net/http/server.go:1918 +0x44`)
+
+	cl := NewClient(WithStackRules(append(DatabaseSQLStackRules(), StackRule{Pattern: "go.nozzle.io/pkg/router.route.*", Action: TruncateBelow})...))
+
+	var got []string
+	for _, f := range cl.parseStack(rawStack) {
+		got = append(got, f.full)
+	}
+
+	assert.Equal(t, []string{
+		"go.nozzle.io/views/billingvw.load",
+		"go.nozzle.io/views/billingvw.Load",
+	}, got)
+}
@@ -1,9 +1,13 @@
 package e
 
 import (
+	"context"
+	"encoding/json"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseStack(t *testing.T) {
@@ -45,67 +49,74 @@ go.nozzle.io/vendor/github.com/dimfeld/httptreemux.(*TreeMux).ServeLookupResult(
 			},
 			stackframes{
 				{
-					file:  "statements.go",
-					path:  "go.nozzle.io/views/billingvw",
-					pkg:   "billingvw",
-					fn:    "humanizeCommafDollars",
-					full:  "go.nozzle.io/views/billingvw.humanizeCommafDollars",
-					line:  75,
-					class: classApp,
+					file:    "statements.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/views/billingvw/statements.go",
+					path:    "go.nozzle.io/views/billingvw",
+					pkg:     "billingvw",
+					fn:      "humanizeCommafDollars",
+					full:    "go.nozzle.io/views/billingvw.humanizeCommafDollars",
+					line:    75,
+					class:   classApp,
 				},
 				{
-					file:  "statements.go",
-					path:  "go.nozzle.io/views/billingvw",
-					pkg:   "billingvw",
-					fn:    "viewStatementTable",
-					full:  "go.nozzle.io/views/billingvw.viewStatementTable",
-					line:  64,
-					class: classApp,
+					file:    "statements.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/views/billingvw/statements.go",
+					path:    "go.nozzle.io/views/billingvw",
+					pkg:     "billingvw",
+					fn:      "viewStatementTable",
+					full:    "go.nozzle.io/views/billingvw.viewStatementTable",
+					line:    64,
+					class:   classApp,
 				},
 				{
-					file:  "statements.go",
-					path:  "go.nozzle.io/views/billingvw",
-					pkg:   "billingvw",
-					fn:    "ViewStatementSlackMessage",
-					full:  "go.nozzle.io/views/billingvw.ViewStatementSlackMessage",
-					line:  23,
-					class: classApp,
+					file:    "statements.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/views/billingvw/statements.go",
+					path:    "go.nozzle.io/views/billingvw",
+					pkg:     "billingvw",
+					fn:      "ViewStatementSlackMessage",
+					full:    "go.nozzle.io/views/billingvw.ViewStatementSlackMessage",
+					line:    23,
+					class:   classApp,
 				},
 				{
-					file:  "reconcile_statements.go",
-					path:  "go.nozzle.io/namespaces/billing/billing/internal/billingserver",
-					pkg:   "billingserver",
-					fn:    "reconcileStatement",
-					full:  "go.nozzle.io/namespaces/billing/billing/internal/billingserver.reconcileStatement",
-					line:  165,
-					class: classApp,
+					file:    "reconcile_statements.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/namespaces/billing/billing/internal/billingserver/reconcile_statements.go",
+					path:    "go.nozzle.io/namespaces/billing/billing/internal/billingserver",
+					pkg:     "billingserver",
+					fn:      "reconcileStatement",
+					full:    "go.nozzle.io/namespaces/billing/billing/internal/billingserver.reconcileStatement",
+					line:    165,
+					class:   classApp,
 				},
 				{
-					file:  "reconcile_statements.go",
-					path:  "go.nozzle.io/namespaces/billing/billing/internal/billingserver",
-					pkg:   "billingserver",
-					fn:    "handleReconcileStatement",
-					full:  "go.nozzle.io/namespaces/billing/billing/internal/billingserver.handleReconcileStatement",
-					line:  76,
-					class: classApp,
+					file:    "reconcile_statements.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/namespaces/billing/billing/internal/billingserver/reconcile_statements.go",
+					path:    "go.nozzle.io/namespaces/billing/billing/internal/billingserver",
+					pkg:     "billingserver",
+					fn:      "handleReconcileStatement",
+					full:    "go.nozzle.io/namespaces/billing/billing/internal/billingserver.handleReconcileStatement",
+					line:    76,
+					class:   classApp,
 				},
 				{
-					file:  "router.go",
-					path:  "github.com/nozzle/rtr",
-					pkg:   "rtr",
-					fn:    "route.func1",
-					full:  "github.com/nozzle/rtr.route.func1",
-					line:  122,
-					class: classVendor,
+					file:    "router.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/rtr/router.go",
+					path:    "github.com/nozzle/rtr",
+					pkg:     "rtr",
+					fn:      "route.func1",
+					full:    "github.com/nozzle/rtr.route.func1",
+					line:    122,
+					class:   classVendor,
 				},
 				{
-					file:  "router.go",
-					path:  "go.nozzle.io/vendor/github.com/dimfeld/httptreemux",
-					pkg:   "httptreemux",
-					fn:    "(*TreeMux).ServeLookupResult",
-					full:  "go.nozzle.io/vendor/github.com/dimfeld/httptreemux.(*TreeMux).ServeLookupResult",
-					line:  245,
-					class: classVendor,
+					file:    "router.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/vendor/github.com/dimfeld/httptreemux/router.go",
+					path:    "go.nozzle.io/vendor/github.com/dimfeld/httptreemux",
+					pkg:     "httptreemux",
+					fn:      "(*TreeMux).ServeLookupResult",
+					full:    "go.nozzle.io/vendor/github.com/dimfeld/httptreemux.(*TreeMux).ServeLookupResult",
+					line:    245,
+					class:   classVendor,
 				},
 			},
 		},
@@ -160,121 +171,134 @@ created by net/http.(*Server).Serve
 			},
 			stackframes{
 				{
-					file:  "router.go",
-					path:  "github.com/nozzle/rtr",
-					pkg:   "rtr",
-					fn:    "route.func1.1",
-					full:  "github.com/nozzle/rtr.route.func1.1",
-					line:  84,
-					class: classVendor,
+					file:    "router.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/rtr/router.go",
+					path:    "github.com/nozzle/rtr",
+					pkg:     "rtr",
+					fn:      "route.func1.1",
+					full:    "github.com/nozzle/rtr.route.func1.1",
+					line:    84,
+					class:   classVendor,
 				},
 				{
-					file:  "panic.go",
-					path:  "",
-					pkg:   "",
-					fn:    "panic",
-					full:  "panic",
-					line:  491,
-					class: classPanic,
+					file:    "panic.go",
+					absPath: "/usr/local/Cellar/go/1.9.2/libexec/src/runtime/panic.go",
+					path:    "",
+					pkg:     "",
+					fn:      "panic",
+					full:    "panic",
+					line:    491,
+					class:   classPanic,
 				},
 				{
-					file:  "report.go",
-					path:  "github.com/nozzle/e",
-					pkg:   "e",
-					fn:    "(*reportData).report",
-					full:  "github.com/nozzle/e.(*reportData).report",
-					line:  114,
-					class: classVendor,
+					file:    "report.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/e/report.go",
+					path:    "github.com/nozzle/e",
+					pkg:     "e",
+					fn:      "(*reportData).report",
+					full:    "github.com/nozzle/e.(*reportData).report",
+					line:    114,
+					class:   classVendor,
 				},
 				{
-					file:  "handler.go",
-					path:  "github.com/nozzle/e",
-					pkg:   "e",
-					fn:    "middleware",
-					full:  "github.com/nozzle/e.middleware",
-					line:  23,
-					class: classVendor,
+					file:    "handler.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/e/handler.go",
+					path:    "github.com/nozzle/e",
+					pkg:     "e",
+					fn:      "middleware",
+					full:    "github.com/nozzle/e.middleware",
+					line:    23,
+					class:   classVendor,
 				},
 				{
-					file:  "router.go",
-					path:  "github.com/nozzle/rtr",
-					pkg:   "rtr",
-					fn:    "route.func1",
-					full:  "github.com/nozzle/rtr.route.func1",
-					line:  124,
-					class: classVendor,
+					file:    "router.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/rtr/router.go",
+					path:    "github.com/nozzle/rtr",
+					pkg:     "rtr",
+					fn:      "route.func1",
+					full:    "github.com/nozzle/rtr.route.func1",
+					line:    124,
+					class:   classVendor,
 				},
 				{
-					file:  "router.go",
-					path:  "go.nozzle.io/vendor/github.com/dimfeld/httptreemux",
-					pkg:   "httptreemux",
-					fn:    "(*TreeMux).ServeLookupResult",
-					full:  "go.nozzle.io/vendor/github.com/dimfeld/httptreemux.(*TreeMux).ServeLookupResult",
-					line:  245,
-					class: classVendor,
+					file:    "router.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/vendor/github.com/dimfeld/httptreemux/router.go",
+					path:    "go.nozzle.io/vendor/github.com/dimfeld/httptreemux",
+					pkg:     "httptreemux",
+					fn:      "(*TreeMux).ServeLookupResult",
+					full:    "go.nozzle.io/vendor/github.com/dimfeld/httptreemux.(*TreeMux).ServeLookupResult",
+					line:    245,
+					class:   classVendor,
 				},
 				{
-					file:  "router.go",
-					path:  "go.nozzle.io/vendor/github.com/dimfeld/httptreemux",
-					pkg:   "httptreemux",
-					fn:    "(*TreeMux).ServeHTTP",
-					full:  "go.nozzle.io/vendor/github.com/dimfeld/httptreemux.(*TreeMux).ServeHTTP",
-					line:  266,
-					class: classVendor,
+					file:    "router.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/vendor/github.com/dimfeld/httptreemux/router.go",
+					path:    "go.nozzle.io/vendor/github.com/dimfeld/httptreemux",
+					pkg:     "httptreemux",
+					fn:      "(*TreeMux).ServeHTTP",
+					full:    "go.nozzle.io/vendor/github.com/dimfeld/httptreemux.(*TreeMux).ServeHTTP",
+					line:    266,
+					class:   classVendor,
 				},
 				{
-					file:  "server.go",
-					path:  "net/http",
-					pkg:   "http",
-					fn:    "(*ServeMux).ServeHTTP",
-					full:  "net/http.(*ServeMux).ServeHTTP",
-					line:  2254,
-					class: classStdLib,
+					file:    "server.go",
+					absPath: "/usr/local/Cellar/go/1.9.2/libexec/src/net/http/server.go",
+					path:    "net/http",
+					pkg:     "http",
+					fn:      "(*ServeMux).ServeHTTP",
+					full:    "net/http.(*ServeMux).ServeHTTP",
+					line:    2254,
+					class:   classStdLib,
 				},
 				{
-					file:  "api.go",
-					path:  "go.nozzle.io/vendor/google.golang.org/appengine/internal",
-					pkg:   "internal",
-					fn:    "executeRequestSafely",
-					full:  "go.nozzle.io/vendor/google.golang.org/appengine/internal.executeRequestSafely",
-					line:  156,
-					class: classVendor,
+					file:    "api.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/vendor/google.golang.org/appengine/internal/api.go",
+					path:    "go.nozzle.io/vendor/google.golang.org/appengine/internal",
+					pkg:     "internal",
+					fn:      "executeRequestSafely",
+					full:    "go.nozzle.io/vendor/google.golang.org/appengine/internal.executeRequestSafely",
+					line:    156,
+					class:   classVendor,
 				},
 				{
-					file:  "api.go",
-					path:  "go.nozzle.io/vendor/google.golang.org/appengine/internal",
-					pkg:   "internal",
-					fn:    "handleHTTP",
-					full:  "go.nozzle.io/vendor/google.golang.org/appengine/internal.handleHTTP",
-					line:  124,
-					class: classVendor,
+					file:    "api.go",
+					absPath: "/Users/derek/go/src/go.nozzle.io/vendor/google.golang.org/appengine/internal/api.go",
+					path:    "go.nozzle.io/vendor/google.golang.org/appengine/internal",
+					pkg:     "internal",
+					fn:      "handleHTTP",
+					full:    "go.nozzle.io/vendor/google.golang.org/appengine/internal.handleHTTP",
+					line:    124,
+					class:   classVendor,
 				},
 				{
-					file:  "server.go",
-					path:  "net/http",
-					pkg:   "http",
-					fn:    "HandlerFunc.ServeHTTP",
-					full:  "net/http.HandlerFunc.ServeHTTP",
-					line:  1918,
-					class: classStdLib,
+					file:    "server.go",
+					absPath: "/usr/local/Cellar/go/1.9.2/libexec/src/net/http/server.go",
+					path:    "net/http",
+					pkg:     "http",
+					fn:      "HandlerFunc.ServeHTTP",
+					full:    "net/http.HandlerFunc.ServeHTTP",
+					line:    1918,
+					class:   classStdLib,
 				},
 				{
-					file:  "server.go",
-					path:  "net/http",
-					pkg:   "http",
-					fn:    "serverHandler.ServeHTTP",
-					full:  "net/http.serverHandler.ServeHTTP",
-					line:  2619,
-					class: classStdLib,
+					file:    "server.go",
+					absPath: "/usr/local/Cellar/go/1.9.2/libexec/src/net/http/server.go",
+					path:    "net/http",
+					pkg:     "http",
+					fn:      "serverHandler.ServeHTTP",
+					full:    "net/http.serverHandler.ServeHTTP",
+					line:    2619,
+					class:   classStdLib,
 				},
 				{
-					file:  "server.go",
-					path:  "net/http",
-					pkg:   "http",
-					fn:    "(*conn).serve",
-					full:  "net/http.(*conn).serve",
-					line:  1801,
-					class: classStdLib,
+					file:    "server.go",
+					absPath: "/usr/local/Cellar/go/1.9.2/libexec/src/net/http/server.go",
+					path:    "net/http",
+					pkg:     "http",
+					fn:      "(*conn).serve",
+					full:    "net/http.(*conn).serve",
+					line:    1801,
+					class:   classStdLib,
 				},
 			},
 		},
@@ -307,65 +331,79 @@ created by testing.(*T).Run
 			},
 			stackframes{
 				{
-					file:  "testing.go",
-					path:  "testing",
-					pkg:   "testing",
-					fn:    "tRunner.func1",
-					full:  "testing.tRunner.func1",
-					line:  711,
-					class: classStdLib,
+					file:    "testing.go",
+					absPath: "/usr/local/Cellar/go/1.9.2/libexec/src/testing/testing.go",
+					path:    "testing",
+					pkg:     "testing",
+					fn:      "tRunner.func1",
+					full:    "testing.tRunner.func1",
+					line:    711,
+					class:   classStdLib,
 				},
 				{
-					file:  "panic.go",
-					path:  "",
-					pkg:   "",
-					fn:    "panic",
-					full:  "panic",
-					line:  491,
-					class: classPanic,
+					file:    "panic.go",
+					absPath: "/usr/local/Cellar/go/1.9.2/libexec/src/runtime/panic.go",
+					path:    "",
+					pkg:     "",
+					fn:      "panic",
+					full:    "panic",
+					line:    491,
+					class:   classPanic,
 				},
 				{
-					file:  "errors.go",
-					path:  "github.com/nozzle/e",
-					pkg:   "e",
-					fn:    "(*Err).findFrame",
-					full:  "github.com/nozzle/e.(*Err).findFrame",
-					line:  200,
-					class: classVendor,
+					file:    "errors.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/e/errors.go",
+					path:    "github.com/nozzle/e",
+					pkg:     "e",
+					fn:      "(*Err).findFrame",
+					full:    "github.com/nozzle/e.(*Err).findFrame",
+					line:    200,
+					class:   classVendor,
 				},
 				{
-					file:  "wrap.go",
-					path:  "github.com/nozzle/e",
-					pkg:   "e",
-					fn:    "wrap",
-					full:  "github.com/nozzle/e.wrap",
-					line:  54,
-					class: classVendor,
+					file:    "wrap.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/e/wrap.go",
+					path:    "github.com/nozzle/e",
+					pkg:     "e",
+					fn:      "wrap",
+					full:    "github.com/nozzle/e.wrap",
+					line:    54,
+					class:   classVendor,
 				},
 				{
-					file:  "wrap.go",
-					path:  "github.com/nozzle/e",
-					pkg:   "e",
-					fn:    "Wrap",
-					full:  "github.com/nozzle/e.Wrap",
-					line:  22,
-					class: classVendor,
+					file:    "wrap.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/e/wrap.go",
+					path:    "github.com/nozzle/e",
+					pkg:     "e",
+					fn:      "Wrap",
+					full:    "github.com/nozzle/e.Wrap",
+					line:    22,
+					class:   classVendor,
 				},
 				{
-					file:  "errors_test.go",
-					path:  "github.com/nozzle/e",
-					pkg:   "e",
-					fn:    "TestWrap.func1",
-					full:  "github.com/nozzle/e.TestWrap.func1",
-					line:  39,
-					class: classVendor,
+					file:    "errors_test.go",
+					absPath: "/Users/derek/go/src/github.com/nozzle/e/errors_test.go",
+					path:    "github.com/nozzle/e",
+					pkg:     "e",
+					fn:      "TestWrap.func1",
+					full:    "github.com/nozzle/e.TestWrap.func1",
+					line:    39,
+					class:   classVendor,
 				},
 			},
 		},
 	}
+
+	// classify frames the way they were in Nozzle's monorepo
+	cl := NewClient(WithFrameClasses(FrameClasses{
+		App:    []string{"go.nozzle.io"},
+		Pkg:    []string{"go.nozzle.io/pkg"},
+		Vendor: []string{"go.nozzle.io/vendor"},
+	}))
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := parseStack(tt.stack)
+			got := cl.parseStack(tt.stack)
 
 			// set up an error to check the associate findFrame function
 			err := &Err{frames: tt.want}
@@ -383,3 +421,75 @@ created by testing.(*T).Run
 		})
 	}
 }
+
+func TestLazyFrames(t *testing.T) {
+	err := New("some error", Msg("some message"), With("k", "v"))
+
+	// nothing is resolved until frames are needed
+	assert.False(t, err.framesResolved)
+	assert.Nil(t, err.frames)
+	assert.Equal(t, "some message: some error", err.shortString())
+
+	err.resolveFrames()
+	assert.True(t, err.framesResolved)
+	assert.Nil(t, err.pendingFrame)
+	if assert.NotEmpty(t, err.frames) {
+		assert.Equal(t, "github.com/nozzle/e.TestLazyFrames", err.frames[0].full)
+		assert.Equal(t, "some message", err.frames[0].msg)
+		assert.Equal(t, map[string]interface{}{"k": "v"}, err.frames[0].vars)
+		assert.Equal(t, err.frames[0], err.topAppFrame)
+	}
+	assert.Equal(t, "some message: some error", err.shortString())
+}
+
+func TestLazyFramesConcurrent(t *testing.T) {
+	tr := &testReporter{}
+	cl := NewClient(WithReporter("test", tr))
+	err := New("some error", Msg("some message"), With("k", "v"))
+
+	// the frames are resolved by whichever goroutine gets there first
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		err.Report(context.Background(), ReportWithClient(cl))
+	}()
+	go func() {
+		defer wg.Done()
+		assert.Contains(t, err.Error(), "some message")
+	}()
+	go func() {
+		defer wg.Done()
+		assert.NotEmpty(t, err.Fingerprint())
+	}()
+	go func() {
+		defer wg.Done()
+		_, marshalErr := json.Marshal(err)
+		assert.NoError(t, marshalErr)
+	}()
+	wg.Wait()
+
+	require.NoError(t, cl.Flush(context.Background()))
+	assert.Len(t, tr.reported, 1)
+}
+
+func TestSplitFuncName(t *testing.T) {
+	tests := []struct {
+		full       string
+		importPath string
+		fn         string
+	}{
+		{"github.com/nozzle/e.(*Err).Error", "github.com/nozzle/e", "(*Err).Error"},
+		{"github.com/nozzle/e.TestWrap.func1", "github.com/nozzle/e", "TestWrap.func1"},
+		{"gopkg.in/yaml%2ev3.Unmarshal", "gopkg.in/yaml.v3", "Unmarshal"},
+		{"main.main", "main", "main"},
+		{"runtime.gopanic", "runtime", "gopanic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.full, func(t *testing.T) {
+			importPath, fn := splitFuncName(tt.full)
+			assert.Equal(t, tt.importPath, importPath)
+			assert.Equal(t, tt.fn, fn)
+		})
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"errors"
 	"runtime"
-	"runtime/debug"
 	"strings"
+	"sync/atomic"
 
 	"google.golang.org/api/googleapi"
 	"google.golang.org/grpc/codes"
@@ -37,19 +37,36 @@ func wrap(rawErr error, opts ...WrapOption) *Err {
 	// we could simply use errors.As, but since we expect the vast majority of calls to already be
 	// wrapped, we'll do a fast path type assertion that doubles as creating the err/wasAlreadyWrapped vars
 	err, wasAlreadyWrapped := rawErr.(*Err)
-	if !wasAlreadyWrapped {
+
+	// errors with several causes keep each of them as a child, instead of errors.As
+	// picking the first Err it finds among them
+	multiErr, isMulti := rawErr.(multiUnwrapper)
+
+	// messages of layers between rawErr and the Err, e.g. from fmt.Errorf, which would be lost otherwise
+	var intermediateMsg string
+	if !wasAlreadyWrapped && !isMulti {
 		wasAlreadyWrapped = errors.As(rawErr, &err) // annoyingly allocates
+		if wasAlreadyWrapped {
+			intermediateMsg = intermediateMsgs(rawErr, err)
+		}
 	}
 
 	switch {
-	// if the error is already wrapped and there are no opts to run, exit immediately
-	case wasAlreadyWrapped && len(opts) == 0:
+	// if the error is already wrapped and there are no opts or messages to add, exit immediately
+	case wasAlreadyWrapped && len(opts) == 0 && intermediateMsg == "":
 		err.currentFrameIdx++
 		return err
 
-	// the error hasn't been wrapped, so initialize it
+	// the error hasn't been wrapped, so initialize it, skipping wrap and Wrap/New
 	case !wasAlreadyWrapped:
-		err = newErr(rawErr, debug.Stack())
+		err = newErr(rawErr, callers(2))
+		if isMulti {
+			// like Join, the root error is made of the children, so that their messages
+			// aren't repeated in full by the root and every child
+			children := childErrs(multiErr.Unwrap(), err.pcs)
+			err.rootErr = newJoinError(children)
+			err.setChildren(children)
+		}
 
 	default:
 		// Extract details about the calling function and package
@@ -82,11 +99,12 @@ func wrap(rawErr error, opts ...WrapOption) *Err {
 		opt(err)
 	}
 
+	err.addIntermediateMsg(intermediateMsg)
+
 	switch {
-	// there's a chicken and the egg problem with the skip frame option not being available
-	// until after the stack has been parsed, so this manipulates it if necessary
+	// the skip frame option is applied once the stack is resolved, which is also when it's
+	// known whether the index should advance, so it's left alone for now
 	case !wasAlreadyWrapped && err.skipFrames > 0:
-		err.handleSkipFrameOption()
 
 	// increment the index so we aren't searching every frame every time we go up the stack
 	// this also serves the purpose of resetting index to 0 if findFrame failed. This needs
@@ -98,16 +116,37 @@ func wrap(rawErr error, opts ...WrapOption) *Err {
 	return err
 }
 
-func newErr(rawErr error, stack []byte) *Err {
+// addIntermediateMsg adds the messages of layers between a wrap and the Err to the
+// current frame. They describe what happened below the wrap, so they follow its own message.
+// Wrapping the same chain again doesn't add them twice.
+func (err *Err) addIntermediateMsg(msg string) {
+	if msg == "" || strings.Contains(": "+err.frameMsgs()+": ", ": "+msg+": ") {
+		return
+	}
+
+	f := err.currentFrame()
+	if f.msg == "" {
+		f.msg = msg
+	} else {
+		f.msg += ": " + msg
+	}
+}
+
+// newErr initializes an error with the program counters of its stack, which
+// aren't resolved into frames until they're needed
+func newErr(rawErr error, pcs []uintptr) *Err {
 	err := &Err{
 		rootErr:         rawErr,
-		frames:          parseStack(stack),
+		pcs:             pcs,
 		currentFrameIdx: 0,
 		isRetriable:     true,
-		memStats:        &runtime.MemStats{},
 		Level:           LevelError,
 	}
 
+	if atomic.LoadInt32(&wrapStatsClients) > 0 {
+		err.runtimeStats = captureRuntimeStats()
+	}
+
 	// set default error code
 	switch {
 	case rawErr == context.Canceled:
@@ -126,21 +165,13 @@ func newErr(rawErr error, stack []byte) *Err {
 		err.code = codes.Unknown
 	}
 
-	// load memstats for later consumption
-	runtime.ReadMemStats(err.memStats)
-
-	err.setTopAppFrame()
-
-	// keep the raw stack trace around
-	err.rawStack = stack
-
 	return err
 }
 
 func (err *Err) setTopAppFrame() {
 	// set the top level app frame, which will be used as the main reporting frame
 	// default to the topmost frame in case no app frame is found
-	err.topAppFrame = err.frames[0]
+	err.topAppFrame = nil
 	for _, f := range err.frames {
 		if err.topAppFrame == nil && f.class == classApp {
 			err.topAppFrame = f
@@ -151,16 +182,25 @@ func (err *Err) setTopAppFrame() {
 			err.isPanic = true
 		}
 	}
+
+	if err.topAppFrame == nil && len(err.frames) > 0 {
+		err.topAppFrame = err.frames[0]
+	}
 }
 
-// since the SkipFrame option doesn't run before the stack is parsed and other
-// options, we have to manually edit the stack
+// since the SkipFrame option runs before the stack is resolved, the resolved
+// stack has to be edited manually
 func (err *Err) handleSkipFrameOption() {
 	switch {
 	// the caller can't skip more frames than there are, and there has to be at least
 	// one frame left to attach details to
 	case err.skipFrames >= len(err.frames):
-		With("invalidSkipFrameCount", err.skipFrames)(err)
+		// With can't be used, since it would resolve the frames again
+		f := err.resolvedFrame()
+		if f.vars == nil {
+			f.vars = make(map[string]interface{})
+		}
+		f.vars["invalidSkipFrameCount"] = err.skipFrames
 
 	// we're now left with a valid number of frames to skip, so we don't have to do
 	// any additional length checks to avoid panics
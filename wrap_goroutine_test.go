@@ -5,13 +5,14 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/maxatome/go-testdeep/td"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/codes"
 )
 
 func TestWrapGoroutine(t *testing.T) {
 	// makes it easier to change test cases when line numbers change
-	const testLine = 95
+	const testLine = 98
 
 	tests := []struct {
 		name   string
@@ -32,8 +33,8 @@ func TestWrapGoroutine(t *testing.T) {
 						pkg:   "e",
 						fn:    "wrapGoroutineHelper",
 						full:  "github.com/nozzle/e.wrapGoroutineHelper",
-						line:  testLine + 22,
-						class: classVendor,
+						line:  testLine + 26,
+						class: classApp,
 						vars: map[string]interface{}{
 							"helper": "goroutine",
 						},
@@ -45,7 +46,7 @@ func TestWrapGoroutine(t *testing.T) {
 						fn:    "TestWrapGoroutine.func1.1",
 						full:  "github.com/nozzle/e.TestWrapGoroutine.func1.1",
 						line:  testLine,
-						class: classVendor,
+						class: classApp,
 						vars: map[string]interface{}{
 							"k": "v",
 						},
@@ -57,29 +58,31 @@ func TestWrapGoroutine(t *testing.T) {
 					pkg:   "e",
 					fn:    "wrapGoroutineHelper",
 					full:  "github.com/nozzle/e.wrapGoroutineHelper",
-					line:  testLine + 22,
-					class: classVendor,
+					line:  testLine + 26,
+					class: classApp,
 					vars: map[string]interface{}{
 						"helper": "goroutine",
 					},
 				},
-				isRetriable: false,
-				Level:       LevelCritical,
+				framesResolved: true,
+				Level:          LevelCritical,
 			},
 			`some error
 
 Error Code: Unknown
-https://github.com/nozzle/e/blob/main/wrap_goroutine_test.go#L117
+https://github.com/nozzle/e/blob/main/wrap_goroutine_test.go#L124
 
 --- NOT RETRIABLE ---
 
 -------------------------------
 e.wrapGoroutineHelper
- github.com/nozzle/e/wrap_goroutine_test.go:117
+ github.com/nozzle/e/wrap_goroutine_test.go:124
+ https://github.com/nozzle/e/blob/main/wrap_goroutine_test.go#L124
  + helper: "goroutine"
 -------------------------------
 e.TestWrapGoroutine.func1.1
- github.com/nozzle/e/wrap_goroutine_test.go:95
+ github.com/nozzle/e/wrap_goroutine_test.go:98
+ https://github.com/nozzle/e/blob/main/wrap_goroutine_test.go#L98
  + k: "v"
 -------------------------------
 `,
@@ -98,9 +101,13 @@ e.TestWrapGoroutine.func1.1
 			}()
 			wg.Wait()
 
-			// make the error comparable
-			got.rawStack = nil
-			got.memStats = nil
+			// make the error comparable, absolute paths depend on where the repo is checked out
+			for _, f := range got.frames {
+				td.Cmp(t, f.absPath, td.HasSuffix("/wrap_goroutine_test.go"))
+				f.absPath = ""
+			}
+			got.pcs = nil
+			got.runtimeStats = nil
 			got.currentFrameIdx = 0
 
 			assert.Equal(t, tt.want, got)
@@ -2,6 +2,7 @@ package e
 
 import (
 	"encoding/json" //nolint:depguard // this is just for json.RawMessage, and there are import cycles with pkg/json
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
@@ -72,6 +73,16 @@ func NotRetriable() WrapOption {
 	}
 }
 
+// RetryAfter sets how long callers should wait before retrying. It doesn't change
+// whether the error is retriable, so NotRetriable wins whatever the order of the
+// options. It's sent to gRPC clients as an errdetails.RetryInfo.
+// This is error scoped, not frame scoped.
+func RetryAfter(d time.Duration) WrapOption {
+	return func(err *Err) {
+		err.retryAfter = d
+	}
+}
+
 // NoReport denotes that this error should not be reported to Sentry
 func NoReport() WrapOption {
 	return func(err *Err) {
@@ -86,7 +97,8 @@ func Infra() WrapOption {
 	}
 }
 
-// Stack lets you provide a stacktrace as opposed to capturing it internally
+// Stack lets you provide a stacktrace in the format of debug.Stack as opposed to
+// capturing it internally. It's only used if set when the error is first wrapped.
 func Stack(stack []byte) WrapOption {
 	return func(err *Err) {
 		err.rawStack = stack
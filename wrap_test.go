@@ -10,7 +10,7 @@ import (
 
 func TestWrap(t *testing.T) {
 	// makes it easier to change test cases when line numbers change
-	const testLine = 139
+	const testLine = 144
 
 	tests := []struct {
 		name   string
@@ -31,8 +31,8 @@ func TestWrap(t *testing.T) {
 						pkg:   "e",
 						fn:    "wrapHelper4",
 						full:  "github.com/nozzle/e.wrapHelper4",
-						line:  testLine + 23 + 5,
-						class: classVendor,
+						line:  testLine + 23 + 9,
+						class: classApp,
 						msg:   "skip msg",
 						vars: map[string]interface{}{
 							"helper": "final",
@@ -44,8 +44,8 @@ func TestWrap(t *testing.T) {
 						pkg:   "e",
 						fn:    "wrapHelper3",
 						full:  "github.com/nozzle/e.wrapHelper3",
-						line:  testLine + 19 + 5,
-						class: classVendor,
+						line:  testLine + 19 + 9,
+						class: classApp,
 						msg:   "some message",
 						vars: map[string]interface{}{
 							"helper": 3,
@@ -57,8 +57,8 @@ func TestWrap(t *testing.T) {
 						pkg:   "e",
 						fn:    "wrapHelper2",
 						full:  "github.com/nozzle/e.wrapHelper2",
-						line:  testLine + 14 + 5,
-						class: classVendor,
+						line:  testLine + 14 + 9,
+						class: classApp,
 						vars: map[string]interface{}{
 							"helper": 2,
 						},
@@ -69,8 +69,8 @@ func TestWrap(t *testing.T) {
 						pkg:   "e",
 						fn:    "wrapHelper1",
 						full:  "github.com/nozzle/e.wrapHelper1",
-						line:  testLine + 9 + 5,
-						class: classVendor,
+						line:  testLine + 9 + 9,
+						class: classApp,
 						vars: map[string]interface{}{
 							"helper": 1,
 						},
@@ -82,7 +82,7 @@ func TestWrap(t *testing.T) {
 						fn:    "TestWrap.func1",
 						full:  "github.com/nozzle/e.TestWrap.func1",
 						line:  testLine,
-						class: classVendor,
+						class: classApp,
 					},
 				},
 				topAppFrame: &frame{
@@ -91,45 +91,50 @@ func TestWrap(t *testing.T) {
 					pkg:   "e",
 					fn:    "wrapHelper4",
 					full:  "github.com/nozzle/e.wrapHelper4",
-					line:  testLine + 23 + 5,
-					class: classVendor,
+					line:  testLine + 23 + 9,
+					class: classApp,
 					msg:   "skip msg",
 					vars: map[string]interface{}{
 						"helper": "final",
 					},
 				},
-				skipFrames:  1,
-				isRetriable: false,
-				Level:       LevelCritical,
+				skipFrames:     1,
+				framesResolved: true,
+				Level:          LevelCritical,
 			},
 			`some error
 
 Error Code: Unknown
-https://github.com/nozzle/e/blob/main/wrap_test.go#L167
+https://github.com/nozzle/e/blob/main/wrap_test.go#L176
 
 --- NOT RETRIABLE ---
 
 -------------------------------
 *** skip msg
 e.wrapHelper4
- github.com/nozzle/e/wrap_test.go:167
+ github.com/nozzle/e/wrap_test.go:176
+ https://github.com/nozzle/e/blob/main/wrap_test.go#L176
  + helper: "final"
 -------------------------------
 *** some message
 e.wrapHelper3
- github.com/nozzle/e/wrap_test.go:163
+ github.com/nozzle/e/wrap_test.go:172
+ https://github.com/nozzle/e/blob/main/wrap_test.go#L172
  + helper: 3
 -------------------------------
 e.wrapHelper2
- github.com/nozzle/e/wrap_test.go:158
+ github.com/nozzle/e/wrap_test.go:167
+ https://github.com/nozzle/e/blob/main/wrap_test.go#L167
  + helper: 2
 -------------------------------
 e.wrapHelper1
- github.com/nozzle/e/wrap_test.go:153
+ github.com/nozzle/e/wrap_test.go:162
+ https://github.com/nozzle/e/blob/main/wrap_test.go#L162
  + helper: 1
 -------------------------------
 e.TestWrap.func1
- github.com/nozzle/e/wrap_test.go:139
+ github.com/nozzle/e/wrap_test.go:144
+ https://github.com/nozzle/e/blob/main/wrap_test.go#L144
 -------------------------------
 `,
 		},
@@ -138,9 +143,13 @@ e.TestWrap.func1
 		t.Run(tt.name, func(t *testing.T) {
 			got := Wrap(wrapHelper1(), tt.opts...)
 
-			// make the error comparable
-			got.rawStack = nil
-			got.memStats = nil
+			// make the error comparable, absolute paths depend on where the repo is checked out
+			for _, f := range got.frames {
+				td.Cmp(t, f.absPath, td.HasSuffix("/wrap_test.go"))
+				f.absPath = ""
+			}
+			got.pcs = nil
+			got.runtimeStats = nil
 			got.currentFrameIdx = 0
 
 			td.Cmp(t, got, tt.want)